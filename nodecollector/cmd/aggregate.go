@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// peerTimeout bounds each request to a peer, so one unreachable node can't
+// stall a fleet query.
+const peerTimeout = 5 * time.Second
+
+var (
+	aggregatePeers    []*peer // query APIs of other collectors; empty disables aggregator mode
+	aggregateInterval = 15 * time.Second
+)
+
+var peerClient = &http.Client{Timeout: peerTimeout}
+
+// peer is another collector's query API, as seen by the aggregator.
+type peer struct {
+	addr string // as given to -aggregate, used as the node key
+	base string // URL prefix, e.g. http://host1:3100
+
+	mu            sync.Mutex
+	lastSeen      time.Time
+	lastError     string
+	lastErrorTime time.Time
+}
+
+func newPeer(addr string) *peer {
+	base := addr
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &peer{addr: addr, base: strings.TrimSuffix(base, "/")}
+}
+
+// get fetches path from the peer and records whether it answered.
+func (p *peer) get(ctx context.Context, path string) ([]byte, error) {
+	b, err := p.fetch(ctx, path)
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.lastError, p.lastErrorTime = err.Error(), now
+		return nil, err
+	}
+	p.lastSeen = now
+	return b, nil
+}
+
+func (p *peer) fetch(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// pollPeersLoop pings every peer each aggregateInterval so /nodes shows
+// which are reachable even when nobody is querying them.
+func pollPeersLoop(ctx context.Context) {
+	t := time.NewTicker(aggregateInterval)
+	defer t.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, p := range aggregatePeers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.get(ctx, "/ping")
+			}()
+		}
+		wg.Wait()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// nodeStatus is one peer's entry in /nodes.
+type nodeStatus struct {
+	Node          string     `json:"node"`
+	LastSeen      *time.Time `json:"last_seen,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_ts,omitempty"`
+}
+
+func nodesHandler(w http.ResponseWriter, r *http.Request) {
+	out := make([]nodeStatus, 0, len(aggregatePeers))
+	for _, p := range aggregatePeers {
+		p.mu.Lock()
+		st := nodeStatus{Node: p.addr, LastError: p.lastError}
+		if !p.lastSeen.IsZero() {
+			t := p.lastSeen
+			st.LastSeen = &t
+		}
+		if !p.lastErrorTime.IsZero() {
+			t := p.lastErrorTime
+			st.LastErrorTime = &t
+		}
+		p.mu.Unlock()
+		out = append(out, st)
+	}
+	writeJSON(w, r, out)
+}
+
+// nodeResult is one peer's answer to a fleet query: its response body, or
+// why it could not be reached.
+type nodeResult struct {
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// fleetHistoryHandler forwards a /history query, params and all, to every
+// peer concurrently and returns the answers keyed by node.
+func fleetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if f := r.URL.Query().Get("format"); f != "" && f != "json" {
+		writeError(w, r, 400, "only json format is supported for fleet queries")
+		return
+	}
+	path := "/history"
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	out := make(map[string]nodeResult, len(aggregatePeers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range aggregatePeers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res nodeResult
+			if b, err := p.get(r.Context(), path); err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Data = b
+			}
+			mu.Lock()
+			out[p.addr] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	writeJSON(w, r, out)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Collector is one source of node stats. Collect returns the stats it read,
+// keyed by their NodeVmstat JSON names, and is called once per sample
+// interval from a single goroutine, so implementations may keep state
+// between calls. On error, whatever it returns is still merged, so a
+// collector can report partial data or carry values forward.
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) (map[string]any, error)
+}
+
+// collectorRegistry lists every collector, in registration order.
+var collectorRegistry []Collector
+
+var (
+	activeMu         sync.Mutex
+	activeCollectors []Collector // the registry less -disable, sampled by collectNodeLoop
+)
+
+// enabledCollectors returns the collectors to sample.
+func enabledCollectors() []Collector {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if activeCollectors == nil {
+		return collectorRegistry
+	}
+	return activeCollectors
+}
+
+// registerCollector adds c to the collectors sampled each interval.
+func registerCollector(c Collector) {
+	collectorRegistry = append(collectorRegistry, c)
+}
+
+// collect reads c, turning a panic into an error so one broken source is
+// recorded as a failure instead of taking down the collection loop.
+func collect(ctx context.Context, c Collector) (out map[string]any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if !collectorErrs.failing(c.Name()) { // once per failure streak, like record
+				slog.Error("collector panicked", "collector", c.Name(), "panic", p, "stack", string(debug.Stack()))
+			}
+			out, err = nil, fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return c.Collect(ctx)
+}
+
+// disableCollectors samples every registered collector except the named
+// ones, whose fields stay zero and which never appear as failed. It can be
+// called again to change the set while collectNodeLoop runs.
+func disableCollectors(names []string) error {
+	off := map[string]bool{}
+	for _, n := range names {
+		off[n] = true
+	}
+	kept := []Collector{}
+	for _, c := range collectorRegistry {
+		if off[c.Name()] {
+			delete(off, c.Name())
+			continue
+		}
+		kept = append(kept, c)
+	}
+	if len(off) > 0 {
+		return fmt.Errorf("unknown collector %q", slices.Sorted(maps.Keys(off))[0])
+	}
+	activeMu.Lock()
+	activeCollectors = kept
+	activeMu.Unlock()
+	return nil
+}
+
+func collectorNames(cs []Collector) []string {
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// collectorStatus is the health of one metric source.
+type collectorStatus struct {
+	Name                string     `json:"name"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorTime       *time.Time `json:"last_error_ts,omitempty"`
+	ConsecutiveFailures uint64     `json:"consecutive_failures"`
+	TotalFailures       uint64     `json:"total_failures"`
+}
+
+// collectorHealth tracks the outcome of every collector read so failures
+// surface on /debug/collectors instead of as stale-looking zeros.
+type collectorHealth struct {
+	mu sync.Mutex
+	m  map[string]*collectorStatus
+}
+
+var collectorErrs = &collectorHealth{m: map[string]*collectorStatus{}}
+
+// record notes the result of a read from the named collector and reports
+// whether it failed. Transitions into and out of failure are logged.
+func (h *collectorHealth) record(name string, err error) bool {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.m[name]
+	if !ok {
+		st = &collectorStatus{Name: name}
+		h.m[name] = st
+	}
+	if err == nil {
+		if st.ConsecutiveFailures > 0 {
+			slog.Info("collector recovered", "collector", name, "failures", st.ConsecutiveFailures)
+		}
+		st.ConsecutiveFailures = 0
+		st.LastSuccess = &now
+		return false
+	}
+	if st.ConsecutiveFailures == 0 {
+		slog.Warn("collector failed", "collector", name, "err", err)
+	}
+	st.ConsecutiveFailures++
+	st.TotalFailures++
+	st.LastError = err.Error()
+	st.LastErrorTime = &now
+	return true
+}
+
+// failing reports whether the named collector's last read failed.
+func (h *collectorHealth) failing(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.m[name]
+	return ok && st.ConsecutiveFailures > 0
+}
+
+// snapshot returns a copy of every collector's status, sorted by name.
+func (h *collectorHealth) snapshot() []collectorStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]collectorStatus, 0, len(h.m))
+	for _, st := range h.m {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func collectorsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, collectorErrs.snapshot())
+}
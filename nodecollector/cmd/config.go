@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+var (
+	configPath    string              // file loaded by -config; empty when flags alone configure the agent
+	startupConfig map[string][]string // the file's values as loaded at startup, to spot changes a reload can't apply
+	commandLine   map[string]bool     // flags given on the command line, which the file never overrides
+)
+
+// repeatableFlags take one value per Set, so a list in the config file sets
+// them once per item rather than as a comma-separated string.
+var repeatableFlags = map[string]bool{"rule": true}
+
+// readConfigFile reads the YAML or JSON file at path, whose keys are flag
+// names without the dash, and renders each value as the flag values to
+// set.
+func readConfigFile(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(b, &settings); err != nil { // YAML is a superset of JSON
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := make(map[string][]string, len(settings))
+	for k, v := range settings {
+		if flag.Lookup(k) == nil || k == "config" {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, k)
+		}
+		if out[k], err = configValues(k, v); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, k, err)
+		}
+	}
+	return out, nil
+}
+
+// applyConfigFile sets each flag named in the config file at path to its
+// value there, unless it was also given on the command line, which takes
+// precedence. The values go through flag.Set, so the file is validated
+// like the command line.
+func applyConfigFile(path string) error {
+	settings, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	startupConfig = settings
+	// Recorded before the file's values are set, since flag.Visit can't
+	// tell them apart afterwards.
+	commandLine = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { commandLine[f.Name] = true })
+	for _, k := range slices.Sorted(maps.Keys(settings)) {
+		if commandLine[k] {
+			continue
+		}
+		for _, v := range settings[k] {
+			if err := flag.Set(k, v); err != nil {
+				return fmt.Errorf("%s: invalid value %q for %s: %w", path, v, k, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValues renders a config file value as the flag values to set. A
+// list becomes a comma-separated value, or one value per item for
+// repeatable flags, and a map becomes key=value pairs, as -node-labels
+// takes them.
+func configValues(name string, v any) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return []string{""}, nil
+	case []any:
+		items := make([]string, len(t))
+		for i, e := range t {
+			s, err := configScalar(e)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = s
+		}
+		if repeatableFlags[name] {
+			return items, nil
+		}
+		return []string{strings.Join(items, ",")}, nil
+	case map[string]any:
+		pairs := make([]string, 0, len(t))
+		for k, e := range t {
+			s, err := configScalar(e)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, k+"="+s)
+		}
+		slices.Sort(pairs)
+		return []string{strings.Join(pairs, ",")}, nil
+	}
+	s, err := configScalar(v)
+	return []string{s}, err
+}
+
+func configScalar(v any) (string, error) {
+	switch v.(type) {
+	case []any, map[string]any:
+		return "", fmt.Errorf("nested value %v", v)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// reloadableFlags are the settings a SIGHUP applies live. Any other change
+// in the config file is only logged, as needing a restart.
+var reloadableFlags = []string{"disable", "log-level", "rule"}
+
+// reloadOnSIGHUP re-reads the config file on every SIGHUP until ctx is
+// done.
+func reloadOnSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		if configPath == "" {
+			slog.Warn("SIGHUP ignored: no -config file to reload")
+			continue
+		}
+		if err := reloadConfig(configPath); err != nil {
+			slog.Error("reloading config; keeping the current one", "path", configPath, "err", err)
+		}
+	}
+}
+
+// reloadConfig applies the reloadableFlags from the config file at path,
+// leaving the history rings and open streams alone. As at startup, a flag
+// given on the command line wins, and one missing from the file reverts to
+// its default. Nothing is applied unless every reloadable value is valid.
+func reloadConfig(path string) error {
+	settings, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	value := func(name string) ([]string, bool) {
+		if commandLine[name] {
+			return nil, false
+		}
+		if v, ok := settings[name]; ok {
+			return v, true
+		}
+		return []string{flag.Lookup(name).DefValue}, true
+	}
+
+	var level slog.Level
+	levelVals, setLevel := value("log-level")
+	if setLevel {
+		if err := level.UnmarshalText([]byte(strings.Join(levelVals, ""))); err != nil {
+			return fmt.Errorf("log-level: %w", err)
+		}
+	}
+	var rules []*thresholdRule
+	ruleVals, setRules := value("rule")
+	for _, v := range ruleVals {
+		if v == "" {
+			continue
+		}
+		r, err := parseRule(v)
+		if err != nil {
+			return fmt.Errorf("rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if disable, ok := value("disable"); ok {
+		// Applied first: it is the last step that can fail.
+		if err := disableCollectors(splitList(strings.Join(disable, ","))); err != nil {
+			return fmt.Errorf("disable: %w", err)
+		}
+	}
+	if setRules {
+		replaceRules(rules)
+	}
+	if setLevel {
+		logLevel.Set(level)
+	}
+
+	keys := map[string]bool{}
+	for k := range settings {
+		keys[k] = true
+	}
+	for k := range startupConfig {
+		keys[k] = true
+	}
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		if !slices.Contains(reloadableFlags, k) && !commandLine[k] && !slices.Equal(settings[k], startupConfig[k]) {
+			slog.Warn("config change needs a restart to apply", "setting", k)
+		}
+	}
+	rulesMu.Lock()
+	nRules := len(thresholdRules)
+	rulesMu.Unlock()
+	slog.Info("config reloaded", "path", path, "collectors", collectorNames(enabledCollectors()), "rules", nRules, "log_level", logLevel.Level().String())
+	return nil
+}
+
+// secretFlags hold credentials that the effective config must not log.
+var secretFlags = map[string]bool{"ingest-token": true}
+
+// logEffectiveConfig logs every flag's final value, whether it came from
+// the command line, the config file or the default. Secrets are redacted,
+// as are passwords in URLs.
+func logEffectiveConfig() {
+	var attrs []any
+	flag.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		switch {
+		case f.Name == "rule":
+			texts := make([]string, len(thresholdRules))
+			for i, r := range thresholdRules {
+				texts[i] = r.text
+			}
+			v = strings.Join(texts, "; ")
+		case secretFlags[f.Name] && v != "":
+			v = "REDACTED"
+		case strings.Contains(v, "://"):
+			if u, err := url.Parse(v); err == nil {
+				v = u.Redacted()
+			}
+		}
+		attrs = append(attrs, f.Name, v)
+	})
+	slog.Info("effective config", attrs...)
+}
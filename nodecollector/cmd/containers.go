@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// containerInfo is what the ingested events say about one container.
+type containerInfo struct {
+	ID         string    `json:"id"`
+	State      string    `json:"state"` // the latest lifecycle state, or created/deleted from the cgroup tracer
+	CgroupPath string    `json:"cgroup_path,omitempty"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// terminalStates are container states after which no more events are
+// expected. Containers in one are forgotten once unseen for historyWindow,
+// so churn doesn't grow the map forever.
+var terminalStates = map[string]bool{"deleted": true, "exited": true, "stopped": true, "dead": true, "removed": true}
+
+var (
+	containersMu sync.Mutex
+	containers   = map[string]*containerInfo{}
+)
+
+// containerIDRe matches the 64-hex-digit ID that containerd, CRI-O and
+// Docker embed in a container's cgroup path, e.g.
+// .../cri-containerd-<id>.scope or .../docker/<id>.
+var containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerIDFromCgroup extracts the container ID from a cgroup path,
+// returning "" when the path doesn't name a container.
+func containerIDFromCgroup(path string) string {
+	ids := containerIDRe.FindAllString(path, -1)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[len(ids)-1] // the innermost, for nested hierarchies
+}
+
+// eventContainerID returns the container an event is about: its
+// container_id, or failing that the ID in its cgroup_path.
+func eventContainerID(ev Event) string {
+	if id, _ := ev["container_id"].(string); id != "" {
+		return id
+	}
+	path, _ := ev["cgroup_path"].(string)
+	return containerIDFromCgroup(path)
+}
+
+// trackContainer updates the container table from a stored event.
+func trackContainer(ev Event) {
+	id := eventContainerID(ev)
+	if id == "" {
+		return
+	}
+	t, ok := eventTime(ev)
+	if !ok {
+		t = time.Now()
+	}
+	var state string
+	switch ev["type"] {
+	case "lifecycle":
+		state, _ = ev["state"].(string)
+	case "container_create":
+		state = "created"
+	case "container_delete":
+		state = "deleted"
+	}
+
+	containersMu.Lock()
+	defer containersMu.Unlock()
+	c, ok := containers[id]
+	if !ok {
+		c = &containerInfo{ID: id, FirstSeen: t, LastSeen: t}
+		containers[id] = c
+	}
+	if t.Before(c.FirstSeen) {
+		c.FirstSeen = t
+	}
+	// Events can arrive out of order; only a newer one changes the state.
+	if !t.Before(c.LastSeen) {
+		c.LastSeen = t
+		if state != "" {
+			c.State = state
+		}
+	}
+	if path, _ := ev["cgroup_path"].(string); path != "" {
+		c.CgroupPath = path
+	}
+
+	cutoff := time.Now().Add(-historyWindow)
+	for id, c := range containers {
+		if terminalStates[c.State] && c.LastSeen.Before(cutoff) {
+			delete(containers, id)
+		}
+	}
+}
+
+// containersHandler lists the containers seen in ingested events, most
+// recently seen first.
+func containersHandler(w http.ResponseWriter, r *http.Request) {
+	containersMu.Lock()
+	out := make([]containerInfo, 0, len(containers))
+	for _, c := range containers {
+		out = append(out, *c)
+	}
+	containersMu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	writeJSON(w, r, out)
+}
+
+// containerEventsHandler serves the retained events about one container.
+func containerEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var out []Event
+	for _, ev := range ctrEvts.snapshot() {
+		if eventContainerID(ev) == id {
+			out = append(out, ev)
+		}
+	}
+	if out == nil {
+		out = []Event{}
+	}
+	writeJSON(w, r, out)
+}
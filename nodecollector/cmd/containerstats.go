@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerSample is one container's cgroup v2 resource usage at an
+// instant.
+type ContainerSample struct {
+	TS               time.Time `json:"ts"`
+	MemCurrentB      uint64    `json:"mem_current_b"`
+	MemMaxB          uint64    `json:"mem_max_b"` // 0 when unlimited
+	CPUUsageUsec     uint64    `json:"cpu_usage_usec"`
+	CPUUserUsec      uint64    `json:"cpu_user_usec"`
+	CPUSystemUsec    uint64    `json:"cpu_system_usec"`
+	CPUNrThrottled   uint64    `json:"cpu_nr_throttled"`
+	CPUThrottledUsec uint64    `json:"cpu_throttled_usec"`
+	CPUPercent       float64   `json:"cpu_percent"` // of one CPU, since the previous sample
+}
+
+var (
+	containerInterval = 10 * time.Second // how often container cgroups are read; 0 disables the collector
+	cgroupRoot        = "/sys/fs/cgroup"
+	containerCgroups  []string // cgroups always collected, besides those discovered from events
+)
+
+var (
+	containerHistMu sync.Mutex
+	containerHist   = map[string]*ring[ContainerSample]{}
+)
+
+// containerStatus is the outcome of the latest container sampling passes,
+// reported on /debug/self. Containers aren't a stats collector, so they
+// don't appear on /debug/collectors.
+type containerStatus struct {
+	Containers    int        `json:"containers"` // sampled in the last pass
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_ts,omitempty"`
+	failing       bool       // the last pass had an error
+}
+
+var (
+	containerStatusMu sync.Mutex
+	lastContainers    containerStatus
+)
+
+func currentContainerStatus() *containerStatus {
+	if containerInterval <= 0 {
+		return nil
+	}
+	containerStatusMu.Lock()
+	defer containerStatusMu.Unlock()
+	st := lastContainers
+	return &st
+}
+
+// recordContainerPass notes the result of a sampling pass that read n
+// containers, logging transitions into and out of failure.
+func recordContainerPass(n int, err error) {
+	now := time.Now()
+	containerStatusMu.Lock()
+	defer containerStatusMu.Unlock()
+	lastContainers.Containers = n
+	if err == nil {
+		if lastContainers.failing {
+			slog.Info("container sampling recovered")
+		}
+		lastContainers.LastSuccess, lastContainers.failing = &now, false
+		return
+	}
+	if !lastContainers.failing {
+		slog.Warn("container sampling failed", "err", err)
+	}
+	lastContainers.failing = true
+	lastContainers.LastError = err.Error()
+	lastContainers.LastErrorTime = &now
+}
+
+// containerSeries returns the retained samples for container id.
+func containerSeries(id string) ([]ContainerSample, bool) {
+	containerHistMu.Lock()
+	h, ok := containerHist[id]
+	containerHistMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return h.snapshot(), true
+}
+
+// cgroupDir resolves a cgroup path as the tracers send it, either absolute
+// under cgroupRoot or relative to it, to its directory. Paths come from
+// ingested events, so one that escapes cgroupRoot (through "..") is
+// rejected rather than read.
+func cgroupDir(path string) (string, bool) {
+	dir := filepath.Join(cgroupRoot, path) // Join cleans the result
+	if strings.HasPrefix(path, cgroupRoot+"/") {
+		dir = filepath.Clean(path)
+	}
+	root := filepath.Clean(cgroupRoot)
+	if dir != root && !strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/") {
+		return "", false
+	}
+	return dir, true
+}
+
+// containerTargets maps each container to collect to its cgroup directory:
+// the live containers whose cgroup_path came in an event, plus
+// containerCgroups.
+func containerTargets() map[string]string {
+	out := map[string]string{}
+	containersMu.Lock()
+	for id, c := range containers {
+		if c.CgroupPath == "" || terminalStates[c.State] {
+			continue
+		}
+		if dir, ok := cgroupDir(c.CgroupPath); ok {
+			out[id] = dir
+		}
+	}
+	containersMu.Unlock()
+	for _, p := range containerCgroups {
+		id := containerIDFromCgroup(p)
+		if id == "" {
+			id = filepath.Base(p)
+		}
+		if dir, ok := cgroupDir(p); ok {
+			out[id] = dir
+		}
+	}
+	return out
+}
+
+// collectContainersLoop samples every target container's cgroup each
+// containerInterval until ctx is done. Containers come and go, so it runs
+// apart from collectNodeLoop, and a container whose cgroup has gone away is
+// skipped rather than failing the sample.
+func collectContainersLoop(ctx context.Context) {
+	prev := map[string]ContainerSample{} // for cpu_percent
+	t := time.NewTicker(containerInterval)
+	defer t.Stop()
+	for {
+		var errs []error
+		targets := containerTargets()
+		for id := range prev {
+			if _, ok := targets[id]; !ok {
+				delete(prev, id) // terminated, or no longer a target
+			}
+		}
+		for id, dir := range targets {
+			s, err := readContainerCgroup(dir)
+			if errors.Is(err, fs.ErrNotExist) {
+				delete(prev, id)
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", id, err))
+				continue
+			}
+			if p, ok := prev[id]; ok && s.CPUUsageUsec >= p.CPUUsageUsec {
+				if secs := s.TS.Sub(p.TS).Seconds(); secs > 0 {
+					s.CPUPercent = float64(s.CPUUsageUsec-p.CPUUsageUsec) / 1e6 / secs * 100
+				}
+			}
+			prev[id] = s
+			appendContainerSample(id, s)
+		}
+		recordContainerPass(len(prev), errors.Join(errs...))
+		pruneContainerHist()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func appendContainerSample(id string, s ContainerSample) {
+	containerHistMu.Lock()
+	h, ok := containerHist[id]
+	if !ok {
+		h = newRing[ContainerSample](int((historyWindow + containerInterval - 1) / containerInterval))
+		containerHist[id] = h
+	}
+	containerHistMu.Unlock()
+	h.append(s)
+}
+
+// pruneContainerHist drops the series of containers not sampled within
+// historyWindow, so churn doesn't grow the map forever.
+func pruneContainerHist() {
+	cutoff := time.Now().Add(-historyWindow)
+	containerHistMu.Lock()
+	defer containerHistMu.Unlock()
+	for id, h := range containerHist {
+		if s, ok := h.latest(); !ok || s.TS.Before(cutoff) {
+			delete(containerHist, id)
+		}
+	}
+}
+
+// readContainerCgroup reads memory.current, memory.max and cpu.stat from a
+// cgroup v2 directory.
+func readContainerCgroup(dir string) (ContainerSample, error) {
+	s := ContainerSample{TS: time.Now()}
+	var err error
+	if s.MemCurrentB, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return s, err
+	}
+	if s.MemMaxB, err = readUint(filepath.Join(dir, "memory.max")); err != nil {
+		return s, err
+	}
+	f, err := os.Open(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "usage_usec":
+			s.CPUUsageUsec = n
+		case "user_usec":
+			s.CPUUserUsec = n
+		case "system_usec":
+			s.CPUSystemUsec = n
+		case "nr_throttled":
+			s.CPUNrThrottled = n
+		case "throttled_usec":
+			s.CPUThrottledUsec = n
+		}
+	}
+	return s, sc.Err()
+}
+
+func containerSampleTime(s ContainerSample) (time.Time, bool) { return s.TS, true }
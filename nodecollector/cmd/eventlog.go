@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventLogSyncInterval is how often buffered event log writes are flushed
+// and fsynced; batching keeps fsync off the ingest path.
+const eventLogSyncInterval = time.Second
+
+var (
+	eventLogPath    string            // append-only JSON-lines log of every accepted event; empty disables it
+	eventLogMaxSize int64  = 64 << 20 // rotate the log once it grows past this many bytes
+	eventLogKeep           = 5        // rotated logs kept, oldest deleted first
+	evLog           *eventLog
+)
+
+// eventLog durably records every accepted event, one JSON object per line,
+// independent of the size-bounded in-memory ring.
+type eventLog struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	w     *bufio.Writer
+	size  int64
+	dirty bool // written since the last sync
+}
+
+func openEventLog(path string) (*eventLog, error) {
+	l := &eventLog{path: path}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *eventLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.w, l.size = f, bufio.NewWriter(f), st.Size()
+	return nil
+}
+
+// append writes ev as a line, rotating the file first if it is full. The
+// line reaches disk on the next sync.
+func (l *eventLog) append(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+int64(len(b))+1 > eventLogMaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.w.Write(append(b, '\n'))
+	l.size += int64(n)
+	l.dirty = true
+	return err
+}
+
+// rotate moves the current file aside under a timestamped name, so rotated
+// logs sort oldest first, and starts a new one. l.mu must be held.
+func (l *eventLog) rotate() error {
+	if err := l.syncLocked(); err != nil {
+		return err
+	}
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	rotated := l.path + "." + time.Now().UTC().Format(rotatedSuffixLayout)
+	if err := os.Rename(l.path, rotated); err != nil {
+		return err
+	}
+	if old := l.rotated(); len(old) > eventLogKeep {
+		for _, p := range old[:len(old)-eventLogKeep] {
+			os.Remove(p)
+		}
+	}
+	return l.open()
+}
+
+// rotatedSuffixLayout is the timestamp rotate appends to the log's name;
+// rotatedSuffix matches it and nothing else.
+const rotatedSuffixLayout = "20060102T150405.000000000Z"
+
+var rotatedSuffix = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z$`)
+
+// rotated lists the rotated log files, oldest first. Other files beside the
+// log, such as backups or editor swap files, are left out, so they are
+// neither replayed nor pruned.
+func (l *eventLog) rotated() []string {
+	matches, _ := filepath.Glob(l.path + ".*")
+	var files []string
+	for _, f := range matches {
+		if suffix, ok := strings.CutPrefix(filepath.Base(f), filepath.Base(l.path)+"."); ok && rotatedSuffix.MatchString(suffix) {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (l *eventLog) sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.syncLocked()
+}
+
+func (l *eventLog) syncLocked() error {
+	if !l.dirty {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return l.f.Sync()
+}
+
+// syncLoop fsyncs the log every eventLogSyncInterval until ctx is done.
+func (l *eventLog) syncLoop(ctx context.Context) {
+	t := time.NewTicker(eventLogSyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := l.sync(); err != nil {
+				slog.Error("syncing event log", "path", l.path, "err", err)
+			}
+		}
+	}
+}
+
+// close syncs and closes the log. Call it once ingestion has stopped.
+func (l *eventLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.syncLocked(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// replayHandler streams the logged events within from/to as JSON lines,
+// oldest first, including those long gone from the in-memory ring.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	tr, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	// Flush buffered lines so the replay includes the latest events.
+	if err := evLog.sync(); err != nil {
+		writeError(w, r, 500, fmt.Sprintf("syncing event log: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, path := range append(evLog.rotated(), evLog.path) {
+		if err := replayFile(w, path, tr); err != nil {
+			slog.Warn("replaying event log", "path", path, "err", err)
+			return
+		}
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+}
+
+func replayFile(w http.ResponseWriter, path string, tr timeRange) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil // rotated away since listing
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The response has started, so a bad line is skipped rather than
+	// cutting the replay short.
+	maxLine := int(maxBodyBytes) + 64<<10 // one ingested event plus its node_stats
+	rd := bufio.NewReader(f)
+	for {
+		line, tooLong, err := readLine(rd, maxLine)
+		if tooLong {
+			slog.Warn("skipping over-long event log line", "path", path, "max_bytes", maxLine)
+		} else if len(line) > 0 {
+			var ev Event
+			if json.Unmarshal(line, &ev) == nil { // otherwise a torn write from a crash
+				if t, ok := eventTime(ev); ok && tr.contains(t) {
+					if _, err := w.Write(append(line, '\n')); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readLine reads the next line from rd, without its newline. A line longer
+// than max bytes is consumed but not returned, and tooLong is set.
+func readLine(rd *bufio.Reader, max int) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := rd.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > max+1 {
+				line, tooLong = nil, true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			return bytes.TrimSuffix(line, []byte("\n")), tooLong, err
+		}
+	}
+}
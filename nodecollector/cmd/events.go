@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v4/process"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requiredEventFields lists the fields each known event type must carry.
+// Types not listed here are accepted as-is for forward compatibility.
+var requiredEventFields = map[string][]string{
+	"oom":                {"victim_pid", "cgroup_path"},
+	"lifecycle":          {"container_id", "state"},
+	"container_create":   {"cgroup_path"},
+	"container_delete":   {"cgroup_path"},
+	"swap_fault_latency": {"latency_distribution_us"},
+}
+
+// prepareEvent validates an ingested event and fills in server-side fields.
+// The returned error is suitable for a 400 response.
+func prepareEvent(ev Event) error {
+	if ev == nil {
+		return errors.New("event must be a JSON object")
+	}
+	t, ok := ev["type"].(string)
+	if !ok || t == "" {
+		return errors.New("invalid node event ingestion: missing type")
+	}
+	var missing []string
+	for _, f := range requiredEventFields[t] {
+		if v, ok := ev[f]; !ok || v == nil {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid %s event: missing required fields: %s", t, strings.Join(missing, ", "))
+	}
+	// Normalize the timestamp so range queries and stats correlation see
+	// one representation; set it if missing.
+	if v, ok := ev["ts"]; ok {
+		ts, err := parseEventTS(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s event: %v", t, err)
+		}
+		ev["ts"] = ts
+	} else {
+		ev["ts"] = time.Now()
+	}
+	return nil
+}
+
+// tracerTSLayout is the strftime("%Y-%m-%dT%H:%M:%S%z") format sent by the
+// oom and cgroup tracers; unlike RFC3339 its offset has no colon.
+const tracerTSLayout = "2006-01-02T15:04:05-0700"
+
+// unixTSUnits tells the unit of a numeric ts from its magnitude: each is
+// used below its limit, which as the next finer unit would be early 1973,
+// and as this unit is thousands of years away.
+var unixTSUnits = []struct {
+	below float64
+	unit  time.Duration
+}{
+	{1e11, time.Second},
+	{1e14, time.Millisecond},
+	{1e17, time.Microsecond},
+	{math.MaxInt64, time.Nanosecond},
+}
+
+// parseEventTS converts an event's ts into a time.Time. It accepts RFC3339
+// (with or without fractional seconds), the tracers' strftime format, and
+// unix seconds, milliseconds, microseconds or nanoseconds.
+func parseEventTS(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(tracerTSLayout, v); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("unrecognized ts %q", v)
+	case float64:
+		if v >= 0 {
+			for _, u := range unixTSUnits {
+				if v < u.below {
+					return time.Unix(0, int64(v*float64(u.unit))), nil
+				}
+			}
+		}
+		return time.Time{}, fmt.Errorf("ts %v is out of range for unix seconds, milliseconds, microseconds or nanoseconds", v)
+	}
+	return time.Time{}, fmt.Errorf("ts must be a string or a number, got %T", v)
+}
+
+// eventTypeLabel is the node_events_total label for an event type: the
+// type itself if known, "other" otherwise. Types come from ingest clients,
+// so passing them through would let any client create unbounded series.
+func eventTypeLabel(t string) string {
+	if _, ok := requiredEventFields[t]; ok || t == "threshold" {
+		return t
+	}
+	return "other"
+}
+
+// storeEvent records an accepted event, enriched with the node stats sample
+// closest to its timestamp for post-mortems.
+func storeEvent(ev Event) {
+	slog.Info("event received", "type", ev["type"])
+	eventsTotal.WithLabelValues(eventTypeLabel(ev["type"].(string))).Inc()
+	if t, ok := eventTime(ev); ok {
+		if s, ok := nearestStat(t); ok {
+			ev["node_stats"] = s
+		}
+	}
+	if ev["type"] == "oom" {
+		enrichOOM(ev)
+	}
+	if evLog != nil {
+		if err := evLog.append(ev); err != nil {
+			slog.Error("writing event log", "path", eventLogPath, "err", err)
+		}
+	}
+	if natsConn != nil {
+		publishEvent(ev)
+	}
+	trackContainer(ev)
+	seq := ctrEvts.append(ev)
+	eventHub.publish(eventFrame(seq, ev))
+}
+
+// enrichOOM attaches the victim's command line and container ID to an oom
+// event so it is actionable on its own. Both are best effort: the victim is
+// usually dead by the time the event arrives, so victim_command falls back
+// to the tracer's victim_comm, and a cgroup path that names no container
+// leaves the tracer's container_id alone. A live process with the victim's
+// PID is only trusted if it started before the event; a later one reused
+// the PID.
+func enrichOOM(ev Event) {
+	pid, ok := ev["victim_pid"].(float64)
+	t, hasTS := eventTime(ev)
+	if ok && hasTS {
+		if p, err := process.NewProcess(int32(pid)); err == nil {
+			if created, err := p.CreateTime(); err == nil && time.UnixMilli(created).Before(t) {
+				if cmd, err := p.Cmdline(); err == nil && cmd != "" {
+					ev["victim_command"] = cmd
+				}
+			}
+		}
+	}
+	if _, ok := ev["victim_command"]; !ok {
+		if comm, _ := ev["victim_comm"].(string); comm != "" {
+			ev["victim_command"] = comm
+		}
+	}
+	path, _ := ev["cgroup_path"].(string)
+	if id := containerIDFromCgroup(path); id != "" {
+		// The tracer's container_id is the victim's UTS hostname, which
+		// doesn't match the IDs /containers reports; keep it alongside.
+		if orig, _ := ev["container_id"].(string); orig != "" && orig != id {
+			ev["tracer_container_id"] = orig
+		}
+		ev["container_id"] = id
+	}
+}
+
+// batchResult summarizes a batch ingest.
+type batchResult struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Errors   []batchError `json:"errors,omitempty"`
+}
+
+type batchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// batchIngestHandler ingests a JSON array of events in order. Invalid events
+// are reported and skipped rather than failing the whole batch.
+func batchIngestHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkIngest(w, r) {
+		return
+	}
+	var evts []Event
+	if !decodeBody(w, r, &evts) {
+		return
+	}
+	if len(evts) > maxBatchSize {
+		writeError(w, r, 413, fmt.Sprintf("batch of %d events exceeds the limit of %d", len(evts), maxBatchSize))
+		return
+	}
+	var res batchResult
+	for i, ev := range evts {
+		if err := prepareEvent(ev); err != nil {
+			res.Rejected++
+			res.Errors = append(res.Errors, batchError{Index: i, Error: err.Error()})
+			continue
+		}
+		storeEvent(ev)
+		res.Accepted++
+	}
+	writeJSON(w, r, res)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// statField is a scalar numeric NodeVmstat field, addressed by its JSON key.
+type statField struct {
+	name    string
+	index   int
+	counter bool // cumulative counter (tagged stat:"counter") rather than a gauge
+}
+
+// statFields lists every scalar numeric NodeVmstat field in declaration
+// order. Slices, maps and the timestamp are skipped.
+var statFields = func() []statField {
+	var out []statField
+	t := reflect.TypeOf(NodeVmstat{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch f.Type.Kind() {
+		case reflect.Float64, reflect.Uint64, reflect.Int64, reflect.Int:
+		default:
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		out = append(out, statField{name: name, index: i, counter: f.Tag.Get("stat") == "counter"})
+	}
+	return out
+}()
+
+// value returns the field's value in s as a float64.
+func (f statField) value(s *NodeVmstat) float64 {
+	v := reflect.ValueOf(s).Elem().Field(f.index)
+	switch v.Kind() {
+	case reflect.Float64:
+		return v.Float()
+	case reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return float64(v.Int())
+	}
+}
+
+// set stores v into the field in s, rounding for integer fields.
+func (f statField) set(s *NodeVmstat, v float64) {
+	fv := reflect.ValueOf(s).Elem().Field(f.index)
+	switch fv.Kind() {
+	case reflect.Float64:
+		fv.SetFloat(v)
+	case reflect.Uint64:
+		fv.SetUint(uint64(math.Max(v, 0) + 0.5))
+	default:
+		fv.SetInt(int64(math.Round(v)))
+	}
+}
+
+// snapshotKeys maps every NodeVmstat JSON key to its field index, so
+// collector output can be merged into a snapshot.
+var snapshotKeys = func() map[string]int {
+	out := map[string]int{}
+	t := reflect.TypeOf(NodeVmstat{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		out[name] = i
+	}
+	return out
+}()
+
+// setKey stores v into the field of s with JSON key key. v must have the
+// field's type.
+func setKey(s *NodeVmstat, key string, v any) error {
+	i, ok := snapshotKeys[key]
+	if !ok {
+		return fmt.Errorf("unknown stat %q", key)
+	}
+	fv := reflect.ValueOf(s).Elem().Field(i)
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Type() != fv.Type() {
+		return fmt.Errorf("stat %q: got %T, want %v", key, v, fv.Type())
+	}
+	fv.Set(rv)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/disk"
+	"time"
+)
+
+// MountUsage is the space and inode usage of one mounted filesystem.
+type MountUsage struct {
+	Mountpoint        string  `json:"mountpoint"`
+	Device            string  `json:"device"`
+	Fstype            string  `json:"fstype"`
+	UsedB             uint64  `json:"used_b"`
+	TotalB            uint64  `json:"total_b"`
+	UsedPercent       float64 `json:"used_percent"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+}
+
+// FSSample is the usage of every collected mount at one instant.
+type FSSample struct {
+	TS     time.Time    `json:"ts"`
+	Mounts []MountUsage `json:"mounts"`
+}
+
+var (
+	fsInterval = 30 * time.Second // how often mounts are sampled; 0 disables the collector
+	fsTypes    map[string]bool    // fstype allowlist; nil means the physical filesystems gopsutil reports by default
+	fsHist     *ring[FSSample]
+)
+
+// collectFSLoop samples filesystem usage every fsInterval until ctx is done.
+// statfs on every mount is too slow for the 1s loop, so it runs on its own
+// cadence like collectProcessLoop.
+func collectFSLoop(ctx context.Context) {
+	t := time.NewTicker(fsInterval)
+	defer t.Stop()
+	for {
+		if s, err := sampleFS(ctx); !collectorErrs.record("fs", err) {
+			fsHist.append(s)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func sampleFS(ctx context.Context) (FSSample, error) {
+	// With an allowlist, list every mount so virtual types such as tmpfs
+	// can be opted into; otherwise only physical filesystems.
+	parts, err := disk.PartitionsWithContext(ctx, fsTypes != nil)
+	if err != nil {
+		return FSSample{}, err
+	}
+	s := FSSample{TS: time.Now(), Mounts: []MountUsage{}}
+	for _, p := range parts {
+		if fsTypes != nil && !fsTypes[p.Fstype] {
+			continue
+		}
+		u, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil {
+			continue // unmounted since listing, or not permitted
+		}
+		s.Mounts = append(s.Mounts, MountUsage{
+			Mountpoint: p.Mountpoint, Device: p.Device, Fstype: p.Fstype,
+			UsedB: u.Used, TotalB: u.Total, UsedPercent: u.UsedPercent,
+			InodesUsed: u.InodesUsed, InodesTotal: u.InodesTotal, InodesUsedPercent: u.InodesUsedPercent,
+		})
+	}
+	return s, nil
+}
+
+func fsTime(s FSSample) (time.Time, bool) { return s.TS, true }
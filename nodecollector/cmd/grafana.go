@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The SimpleJSON datasource API lets Grafana chart the stats history
+// directly: point a SimpleJSON (or JSON API) datasource at /grafana. Each
+// target is a numeric stat, named by its JSON key in -json-style.
+
+// grafanaQuery is the body Grafana POSTs to /grafana/query.
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs int64 `json:"intervalMs"` // the panel's suggested spacing between points
+	Targets    []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is one target's time series: datapoints are [value, unix ms]
+// pairs, oldest first.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaHealthHandler answers the datasource's connection test.
+func grafanaHealthHandler(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+
+// grafanaSearchHandler lists the stats that can be queried, narrowed to
+// those containing the request's target when it names one.
+func grafanaSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if r.ContentLength != 0 { // older Grafana versions send no body
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		if !decodeBody(w, r, &req) {
+			return
+		}
+	}
+	names := []string{}
+	for _, f := range statFields {
+		name := f.name
+		if jsonStyle == "camel" {
+			name = camelKey(name)
+		}
+		if strings.Contains(name, req.Target) {
+			names = append(names, name)
+		}
+	}
+	writeJSON(w, r, names)
+}
+
+// grafanaQueryHandler serves each target's series over the requested range,
+// downsampled to the panel's interval when that is coarser than the
+// sample interval.
+func grafanaQueryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, 405, "POST only")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req grafanaQuery
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if !req.Range.From.IsZero() && !req.Range.To.IsZero() && req.Range.From.After(req.Range.To) {
+		writeError(w, r, 400, fmt.Sprintf("from %v is after to %v", req.Range.From, req.Range.To))
+		return
+	}
+	fields := make([]int, len(req.Targets)) // indexes into statFields
+	for i, t := range req.Targets {
+		name := snakeKey(t.Target)
+		if fields[i] = slices.IndexFunc(statFields, func(f statField) bool { return f.name == name }); fields[i] < 0 {
+			writeError(w, r, 400, fmt.Sprintf("unknown target %q", t.Target))
+			return
+		}
+	}
+	tr := timeRange{from: req.Range.From, to: req.Range.To}
+	var stats []NodeVmstat
+	if d := time.Duration(req.IntervalMs) * time.Millisecond; d > sampleInterval {
+		stats = downsampledStats(tr, d, "")
+	} else {
+		stats = filterRange(nodeHist.snapshot(), tr, statTime)
+	}
+	out := make([]grafanaSeries, len(fields))
+	for i, fi := range fields {
+		points := make([][2]float64, len(stats))
+		for j := range stats {
+			points[j] = [2]float64{statFields[fi].value(&stats[j]), float64(stats[j].TS.UnixMilli())}
+		}
+		// Echoed as sent, so Grafana matches the series to its query.
+		out[i] = grafanaSeries{Target: req.Targets[i].Target, Datapoints: points}
+	}
+	writeJSON(w, r, out)
+}
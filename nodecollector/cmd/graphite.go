@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	graphiteAddr   string // host:port of a Graphite plaintext listener; empty disables the exporter
+	graphitePrefix = "nodecollector.{node}"
+)
+
+const graphiteTimeout = 5 * time.Second
+
+// graphiteLoop sends each new stats sample to graphiteAddr in the Graphite
+// plaintext protocol until ctx is done, for backends that can't scrape
+// /metrics. The connection is kept open between samples; when a send fails
+// it is dropped and redialed on the next sample, and the failure counted
+// in graphiteSendErrors.
+func graphiteLoop(ctx context.Context) {
+	prefix := graphiteMetricPrefix()
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var sent time.Time // timestamp of the last sample sent
+	failing := false
+	t := time.NewTicker(sampleInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		s, ok := nodeHist.latest()
+		if !ok || !s.TS.After(sent) {
+			continue
+		}
+		var err error
+		if conn == nil {
+			d := net.Dialer{Timeout: graphiteTimeout}
+			conn, err = d.DialContext(ctx, "tcp", graphiteAddr)
+		}
+		if err == nil {
+			conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+			if _, err = conn.Write(graphiteLines(prefix, &s)); err != nil {
+				conn.Close()
+				conn = nil
+			}
+		}
+		if err != nil {
+			graphiteSendErrors.Inc()
+			if !failing && ctx.Err() == nil {
+				slog.Warn("graphite send failed; retrying each interval", "addr", graphiteAddr, "err", err)
+			}
+			failing = true
+			continue
+		}
+		if failing {
+			slog.Info("graphite send recovered", "addr", graphiteAddr)
+		}
+		sent, failing = s.TS, false
+	}
+}
+
+// graphiteMetricPrefix expands {node} in graphitePrefix to the node name,
+// with dots replaced so an FQDN stays one path segment.
+func graphiteMetricPrefix() string {
+	return strings.ReplaceAll(graphitePrefix, "{node}", strings.ReplaceAll(nodeName, ".", "_"))
+}
+
+// graphiteLines renders every numeric stat in s as a "path value
+// timestamp" line, the path being prefix and the stat's JSON key.
+func graphiteLines(prefix string, s *NodeVmstat) []byte {
+	var buf bytes.Buffer
+	ts := strconv.FormatInt(s.TS.Unix(), 10)
+	for _, f := range statFields {
+		buf.WriteString(prefix)
+		buf.WriteByte('.')
+		buf.WriteString(f.name)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(f.value(s), 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(ts)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
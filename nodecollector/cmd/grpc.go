@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	pb "github.com/ajaysundark/nodecollector/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+var grpcAddr string // listen address for the gRPC streaming API; empty disables it
+
+// grpcServer implements the NodeCollector service. Subscribe reads from the
+// same subscription, backfill and stream limit as /stream, so the two
+// transports can't diverge; only the encoding differs.
+type grpcServer struct {
+	pb.UnimplementedNodeCollectorServer
+	ctx context.Context // ends every subscription on shutdown, so GracefulStop returns
+}
+
+func newGRPCServer(ctx context.Context) *grpc.Server {
+	srv := grpc.NewServer()
+	pb.RegisterNodeCollectorServer(srv, &grpcServer{ctx: ctx})
+	return srv
+}
+
+func (g *grpcServer) Subscribe(req *pb.SubscribeRequest, stream pb.NodeCollector_SubscribeServer) error {
+	switch req.Scope {
+	case "", "events", "stats", "both":
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid scope %q", req.Scope)
+	}
+	if req.Scope == "both" && req.Backfill != "" {
+		return status.Error(codes.InvalidArgument, "scope both does not support backfill")
+	}
+	if !acquireStream() {
+		return status.Error(codes.ResourceExhausted, "too many streams")
+	}
+	defer activeStreams.Add(-1)
+
+	sub := subscribe(req.Scope)
+	defer sub.close()
+	var backfill []frame
+	if req.Backfill != "" {
+		var err error
+		if backfill, err = backfillFrames(req.Scope, req.Backfill); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	send := func(f frame) error {
+		m, err := protoFrame(f)
+		if err != nil {
+			return status.Errorf(codes.Internal, "encoding frame: %v", err)
+		}
+		return stream.Send(m)
+	}
+	for _, f := range backfill {
+		if err := send(f); err != nil {
+			return err
+		}
+		sub.sent = f.id
+	}
+	for {
+		select {
+		case <-sub.ticks:
+			if f, ok := sub.tick(); ok {
+				if err := send(f); err != nil {
+					return err
+				}
+			}
+		case f := <-sub.events:
+			if f, ok := sub.pushed(f); ok {
+				if err := send(f); err != nil {
+					return err
+				}
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-g.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// protoFrame converts a stream frame's JSON into its proto message. The
+// proto field names match the JSON keys, and protojson also accepts the
+// camelCase ones of -json-style=camel, so the conversion is direct; keys
+// the proto doesn't know yet are dropped rather than failing the stream.
+func protoFrame(f frame) (*pb.Frame, error) {
+	dec := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if f.stats {
+		var s pb.NodeVmstat
+		if err := dec.Unmarshal(f.data, &s); err != nil {
+			return nil, err
+		}
+		return &pb.Frame{Id: f.id, Payload: &pb.Frame_Stats{Stats: &s}}, nil
+	}
+	var fields structpb.Struct
+	if err := dec.Unmarshal(f.data, &fields); err != nil {
+		return nil, err
+	}
+	ev := &pb.Event{Fields: &fields}
+	ev.Type = fields.Fields["type"].GetStringValue()
+	if t, err := time.Parse(time.RFC3339Nano, fields.Fields["ts"].GetStringValue()); err == nil {
+		ev.Ts = timestamppb.New(t)
+	}
+	return &pb.Frame{Id: f.id, Payload: &pb.Frame_Event{Event: ev}}, nil
+}
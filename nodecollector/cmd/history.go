@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeRange is an inclusive [from, to] window; a zero bound is open.
+type timeRange struct{ from, to time.Time }
+
+// parseTimeParam parses an RFC3339 timestamp or unix seconds.
+func parseTimeParam(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC3339 or unix seconds", s)
+}
+
+// parseTimeRange reads the from and to query params.
+func parseTimeRange(q url.Values) (timeRange, error) {
+	var tr timeRange
+	var err error
+	if s := q.Get("from"); s != "" {
+		if tr.from, err = parseTimeParam(s); err != nil {
+			return tr, err
+		}
+	}
+	if s := q.Get("to"); s != "" {
+		if tr.to, err = parseTimeParam(s); err != nil {
+			return tr, err
+		}
+	}
+	if !tr.from.IsZero() && !tr.to.IsZero() && tr.from.After(tr.to) {
+		return tr, fmt.Errorf("from %v is after to %v", tr.from, tr.to)
+	}
+	return tr, nil
+}
+
+func (tr timeRange) open() bool { return tr.from.IsZero() && tr.to.IsZero() }
+
+func (tr timeRange) contains(t time.Time) bool {
+	return (tr.from.IsZero() || !t.Before(tr.from)) && (tr.to.IsZero() || !t.After(tr.to))
+}
+
+// filterRange keeps the items whose timestamp falls within tr. Items without
+// a usable timestamp are dropped unless the range is open.
+func filterRange[T any](in []T, tr timeRange, ts func(T) (time.Time, bool)) []T {
+	if tr.open() {
+		return in
+	}
+	out := in[:0]
+	for _, v := range in {
+		if t, ok := ts(v); ok && tr.contains(t) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// lastN returns the final n items of in; n <= 0 means no limit.
+func lastN[T any](in []T, n int) []T {
+	if n <= 0 || n >= len(in) {
+		return in
+	}
+	return in[len(in)-n:]
+}
+
+func statTime(s NodeVmstat) (time.Time, bool) { return s.TS, true }
+
+// eventTime extracts an event's ts, normalized at ingest to a time.Time but
+// accepted in any form parseEventTS understands.
+func eventTime(ev Event) (time.Time, bool) {
+	t, err := parseEventTS(ev["ts"])
+	return t, err == nil
+}
+
+// filterTypes keeps events whose type is one of types (case-sensitive).
+// Events without a type never match. An empty types list keeps everything.
+func filterTypes(in []Event, types []string) []Event {
+	if len(types) == 0 {
+		return in
+	}
+	want := map[string]bool{}
+	for _, t := range types {
+		want[t] = true
+	}
+	out := in[:0]
+	for _, ev := range in {
+		if t, ok := ev["type"].(string); ok && want[t] {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// writeStatsCSV writes one row per snapshot: the RFC3339 timestamp followed
+// by every scalar numeric field, under a header of their JSON names.
+func writeStatsCSV(w http.ResponseWriter, r *http.Request, stats []NodeVmstat, cols []statField) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	row := make([]string, 0, len(cols)+1)
+	row = append(row, "ts")
+	for _, f := range cols {
+		row = append(row, f.name)
+	}
+	cw.Write(row)
+	for i := range stats {
+		row = append(row[:0], stats[i].TS.Format(time.RFC3339Nano))
+		for _, f := range cols {
+			row = append(row, strconv.FormatFloat(f.value(&stats[i]), 'f', -1, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	w.Header().Set("Content-Type", "text/csv")
+	writeBody(w, r, http.StatusOK, buf.Bytes())
+}
+
+// writeNDJSON streams the items of the slice v as newline-delimited JSON,
+// one object per line, so consumers can process records as they arrive.
+// With env, each line is wrapped with the node identity. Unlike writeBody
+// it can't know the size up front, so it gzips whenever the client accepts
+// it.
+func writeNDJSON(w http.ResponseWriter, r *http.Request, v any, env bool) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Add("Vary", "Accept-Encoding")
+	out := io.Writer(w)
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		out = zw
+	}
+	items := reflect.ValueOf(v)
+	for i := 0; i < items.Len(); i++ {
+		item := items.Index(i).Interface()
+		if env {
+			item = identityEnvelope{Node: nodeName, Labels: nodeLabels, Data: item}
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return
+		}
+		if _, err := out.Write(append(restyleJSON(b), '\n')); err != nil {
+			return // client went away
+		}
+	}
+}
+
+// influxMeasurement is the measurement name of the influx line-protocol
+// output.
+const influxMeasurement = "node_vmstat"
+
+// influxEscaper escapes tag keys, tag values and field keys in line
+// protocol, where commas, equals signs and spaces are delimiters.
+var influxEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `)
+
+// writeStatsInflux renders stats as InfluxDB line protocol, one line per
+// sample: the node_vmstat measurement, the node name and labels as tags,
+// cols as fields and a nanosecond timestamp. Unsigned stats are written as
+// integers, since line protocol's unsigned type is not enabled everywhere.
+func writeStatsInflux(w http.ResponseWriter, r *http.Request, stats []NodeVmstat, cols []statField) {
+	var prefix strings.Builder
+	prefix.WriteString(influxMeasurement)
+	tags := map[string]string{"node": nodeName}
+	for k, v := range nodeLabels {
+		if k != "node" {
+			tags[k] = v
+		}
+	}
+	// Line protocol wants tags sorted by key.
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		if tags[k] == "" {
+			continue // empty tag values are invalid
+		}
+		fmt.Fprintf(&prefix, ",%s=%s", influxEscaper.Replace(k), influxEscaper.Replace(tags[k]))
+	}
+	var buf bytes.Buffer
+	for i := range stats {
+		buf.WriteString(prefix.String())
+		v := reflect.ValueOf(&stats[i]).Elem()
+		for j, f := range cols {
+			sep := ","
+			if j == 0 {
+				sep = " "
+			}
+			buf.WriteString(sep + influxEscaper.Replace(f.name) + "=")
+			switch fv := v.Field(f.index); fv.Kind() {
+			case reflect.Float64:
+				buf.WriteString(strconv.FormatFloat(fv.Float(), 'g', -1, 64))
+			case reflect.Uint64:
+				buf.WriteString(strconv.FormatUint(min(fv.Uint(), math.MaxInt64), 10) + "i")
+			default:
+				buf.WriteString(strconv.FormatInt(fv.Int(), 10) + "i")
+			}
+		}
+		fmt.Fprintf(&buf, " %d\n", stats[i].TS.UnixNano())
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeBody(w, r, http.StatusOK, buf.Bytes())
+}
+
+// identityEnvelope wraps a response with the identity of the node it came
+// from, for clients that merge data from several nodes.
+type identityEnvelope struct {
+	Node   string            `json:"node"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Data   any               `json:"data"`
+}
+
+// ringVersion identifies a ring's current contents by its newest
+// element's sequence number and timestamp.
+func ringVersion[T any](h *ring[T], ts func(T) (time.Time, bool)) string {
+	v, seq, _ := h.latestSeq()
+	t, _ := ts(v)
+	return fmt.Sprintf("%d-%d", seq, t.UnixNano())
+}
+
+// historyETag returns a weak ETag for a /history request, or "" when its
+// scope has no data to version. It changes whenever the newest element
+// behind the scope does, and differs across query params and Accept, so
+// caches keep different views apart. startTime is mixed in because
+// sequence numbers restart with the process.
+func historyETag(r *http.Request, q url.Values) string {
+	var version string
+	switch q.Get("scope") {
+	case "", "events":
+		version = ringVersion(ctrEvts, eventTime)
+	case "stats":
+		version = ringVersion(nodeHist, statTime)
+		if longHist != nil {
+			version += "/" + ringVersion(longHist, statTime)
+		}
+	case "processes":
+		version = ringVersion(procHist, processTime)
+	case "fs":
+		version = ringVersion(fsHist, fsTime)
+	case "thermal":
+		version = ringVersion(thermalHist, thermalTime)
+	case "container":
+		containerHistMu.Lock()
+		h, ok := containerHist[q.Get("id")]
+		containerHistMu.Unlock()
+		if !ok {
+			return ""
+		}
+		version = ringVersion(h, containerSampleTime)
+	default:
+		return ""
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s", startTime.UnixNano(), version, q.Encode(), r.Header.Get("Accept"))
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// notModified sets the ETag header and, if r's If-None-Match already names
+// it, replies 304 and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, t := range strings.Split(inm, ",") {
+		// If-None-Match uses weak comparison, so W/ prefixes don't matter.
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// envelopeParam parses the optional envelope query param.
+func envelopeParam(q url.Values) (bool, error) {
+	s := q.Get("envelope")
+	if s == "" {
+		return false, nil
+	}
+	env, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid envelope %q", s)
+	}
+	return env, nil
+}
+
+// parseFields validates a comma-separated list of stats JSON keys, in
+// either naming style, returning them in snake_case.
+func parseFields(s string) ([]string, error) {
+	fields := splitList(s)
+	for i, f := range fields {
+		f = snakeKey(f) // fields may be named in -json-style=camel
+		fields[i] = f
+		if _, ok := snapshotKeys[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return fields, nil
+}
+
+// scalarColumns returns the columns for fields in the tabular formats (CSV
+// and influx), all scalar stats when fields is empty. Slice and map fields
+// have no column.
+func scalarColumns(fields []string) ([]statField, error) {
+	if len(fields) == 0 {
+		return statFields, nil
+	}
+	var cols []statField
+	for _, name := range fields {
+		i := slices.IndexFunc(statFields, func(f statField) bool { return f.name == name })
+		if i < 0 {
+			if name == "ts" {
+				continue // always the first column
+			}
+			return nil, fmt.Errorf("field %q is not a scalar stat", name)
+		}
+		cols = append(cols, statFields[i])
+	}
+	return cols, nil
+}
+
+// project reduces each sample to its timestamp and the named fields, keyed
+// by JSON name, so clients only receive what they chart.
+func project(stats []NodeVmstat, fields []string) []map[string]any {
+	out := make([]map[string]any, len(stats))
+	for i := range stats {
+		v := reflect.ValueOf(&stats[i]).Elem()
+		m := map[string]any{"ts": stats[i].TS}
+		for _, f := range fields {
+			m[f] = v.Field(snapshotKeys[f]).Interface()
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// downsample buckets stats by resolution and reduces each numeric field with
+// agg (avg, max, min or last). With no agg, gauges are averaged and counters
+// take their last value. Buckets are stamped with their start time; slice
+// and map fields are taken from the bucket's last sample.
+func downsample(stats []NodeVmstat, resolution time.Duration, agg string) []NodeVmstat {
+	var out []NodeVmstat
+	for i := 0; i < len(stats); {
+		start := stats[i].TS.Truncate(resolution)
+		j := i + 1
+		for j < len(stats) && stats[j].TS.Truncate(resolution).Equal(start) {
+			j++
+		}
+		bucket := stats[i:j]
+		s := bucket[len(bucket)-1]
+		s.TS = start
+		for _, b := range bucket {
+			s.CounterReset = s.CounterReset || b.CounterReset
+		}
+		for _, f := range statFields {
+			fn := agg
+			if fn == "" {
+				fn = "avg"
+				if f.counter {
+					fn = "last"
+				}
+			}
+			f.set(&s, reduce(bucket, f, fn))
+		}
+		out = append(out, s)
+		i = j
+	}
+	return out
+}
+
+func reduce(bucket []NodeVmstat, f statField, agg string) float64 {
+	acc := f.value(&bucket[0])
+	for i := 1; i < len(bucket); i++ {
+		v := f.value(&bucket[i])
+		switch agg {
+		case "avg":
+			acc += v
+		case "max":
+			acc = max(acc, v)
+		case "min":
+			acc = min(acc, v)
+		case "last":
+			acc = v
+		}
+	}
+	if agg == "avg" {
+		acc /= float64(len(bucket))
+	}
+	return acc
+}
+
+// validAgg reports whether agg names a downsampling function.
+func validAgg(agg string) bool {
+	switch agg {
+	case "avg", "max", "min", "last":
+		return true
+	}
+	return false
+}
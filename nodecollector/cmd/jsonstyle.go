@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"strings"
+	"unicode"
+)
+
+// jsonStyle is the key naming of the query API's documents: "snake", as in
+// the struct tags, or "camel".
+var jsonStyle = "snake"
+
+// dataKeyed are the keys whose object values are keyed by data (labels,
+// device and interface names) rather than schema, so their own keys are
+// left as they are.
+var dataKeyed = map[string]bool{"labels": true, "per_disk": true, "per_net": true}
+
+// camelKey converts a snake_case key to camelCase, e.g. mem_used_mb to
+// memUsedMb.
+func camelKey(k string) string {
+	parts := strings.Split(k, "_")
+	for i := 1; i < len(parts); i++ {
+		if p := parts[i]; p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// snakeKey converts a camelCase key back to snake_case, so query params
+// can name fields in either style.
+func snakeKey(k string) string {
+	var b strings.Builder
+	for _, c := range k {
+		if unicode.IsUpper(c) {
+			b.WriteByte('_')
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// restyleJSON renames the object keys of an encoded JSON document to
+// jsonStyle, keeping their order and the values byte for byte. With the
+// default style it returns b unchanged.
+func restyleJSON(b []byte) []byte {
+	if jsonStyle != "camel" {
+		return b
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := restyleValue(dec, &out, false); err != nil {
+		return b
+	}
+	if bytes.HasSuffix(b, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// restyleValue copies one JSON value from dec to out, renaming object keys
+// unless keepKeys is set.
+func restyleValue(dec *json.Decoder, out *bytes.Buffer, keepKeys bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		obj := t == '{'
+		out.WriteRune(rune(t))
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			var dataKeys bool
+			if obj {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := k.(string)
+				dataKeys = !keepKeys && dataKeyed[key]
+				if !keepKeys {
+					key = camelKey(key)
+				}
+				writeJSONString(out, key)
+				out.WriteByte(':')
+			}
+			if err := restyleValue(dec, out, dataKeys); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // the closing delimiter
+			return err
+		}
+		if obj {
+			out.WriteByte('}')
+		} else {
+			out.WriteByte(']')
+		}
+	case string:
+		writeJSONString(out, t)
+	case json.Number:
+		out.WriteString(t.String())
+	case nil:
+		out.WriteString("null")
+	default:
+		fmt.Fprint(out, t)
+	}
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	out.Write(b)
+}
+
+// restyleMsgpack is restyleJSON for msgpack documents. Map order carries no
+// meaning there, so the document is decoded, renamed and re-encoded.
+func restyleMsgpack(b []byte) ([]byte, error) {
+	if jsonStyle != "camel" {
+		return b, nil
+	}
+	var v any
+	if err := msgpack.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(restyleKeys(v, false)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func restyleKeys(v any, keepKeys bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			if keepKeys {
+				out[k] = restyleKeys(e, false)
+			} else {
+				out[camelKey(k)] = restyleKeys(e, dataKeyed[k])
+			}
+		}
+		return out
+	case []any:
+		for i, e := range t {
+			t[i] = restyleKeys(e, false)
+		}
+	}
+	return v
+}
@@ -3,75 +3,568 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/shirou/gopsutil/v4/disk"
-	"github.com/shirou/gopsutil/v4/mem"
-	"log"
+	psnet "github.com/shirou/gopsutil/v4/net"
+	"github.com/vmihailenco/msgpack/v5"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 const (
+	// shutdownTimeout bounds how long in-flight requests get to finish on exit.
+	shutdownTimeout = 10 * time.Second
+	// gzipMinSize is the smallest response body worth compressing.
+	gzipMinSize = 1024
+)
+
+var (
 	sampleInterval = time.Second
-	historySeconds = 900 // 15m @ 1s
+	historyWindow  = 15 * time.Minute
+	historySize    = 900 // stats ring capacity, derived from historyWindow / sampleInterval
+	eventHistory   = 900 // events ring capacity; events are irregular so it is sized independently
+	eventBytes     int   // events ring byte budget, by marshaled size; 0 means count only
+
+	queryAddr  = ":3100"
+	ingestAddr = ":3101"
+
+	netInterfaces map[string]bool // allowlist; nil means all interfaces
+	perInterface  bool            // include the per-interface breakdown in snapshots
+
+	diskInclude        *regexp.Regexp // only devices matching are collected; nil means all
+	diskExclude        *regexp.Regexp // devices matching are dropped, after diskInclude; nil drops none
+	diskSkipPartitions = true         // leave partitions out of the aggregate when their parent disk is present
+
+	sseKeepalive       time.Duration      // idle time before /stream sends a keepalive comment; 0 means sampleInterval
+	maxStreams         = 100              // most concurrent /stream connections; 0 means unlimited
+	streamWriteTimeout = 10 * time.Second // how long a /stream client may take to absorb a frame
+
+	stateFile     string // where history is persisted across restarts; empty disables persistence
+	stateInterval        = 30 * time.Second
+
+	swapElevatedRate  uint64 = 100  // swap_pressure (pages/s) at which swap_level becomes "elevated"
+	swapThrashingRate uint64 = 1000 // swap_pressure (pages/s) at which swap_level becomes "thrashing"
+
+	nodeName   string            // identifies this node in enveloped responses; defaults to the hostname
+	nodeLabels map[string]string // extra key=value identity for enveloped responses
+
+	allowOrigins map[string]bool // origins allowed cross-origin access to the query API; "*" allows any, nil disables CORS
+
+	pprofEnabled bool // serve net/http/pprof under /debug/pprof/ on the query API
+
+	logLevel = new(slog.LevelVar) // minimum level logged; -log-level, changeable by a reload
+
+	ingestToken  string             // bearer token required by the ingest API; empty leaves it open
+	maxBatchSize        = 1000      // most events accepted by one /events/batch request
+	maxBodyBytes int64  = 256 << 10 // largest ingest request body accepted
 )
 
 // NodeVmstat is a snapshot of the node's vmstat.
 type NodeVmstat struct {
-	TS          time.Time `json:"ts"`
-	CPUPercent  float64   `json:"cpu_percent"`
-	MemUsedMB   uint64    `json:"mem_used_mb"`
-	MemTotalMB  uint64    `json:"mem_total_mb"`
-	SwapUsedMB  uint64    `json:"swap_used_mb"`
-	SwapTotalMB uint64    `json:"swap_total_mb"`
-	Pswpin      uint64    `json:"pswpin"`
-	Pswpout     uint64    `json:"pswpout"`
-	Pgfault     uint64    `json:"pgfault"`
-	Pgmajfault  uint64    `json:"pgmajfault"`
-	Pgpgin      uint64    `json:"pgpgin"`
-	Pgpgout     uint64    `json:"pgpgout"`
-	DiskReadB   uint64    `json:"disk_read_b"`
-	DiskWriteB  uint64    `json:"disk_write_b"`
+	TS                 time.Time         `json:"ts"` // when sampling began
+	CPUPercent         float64           `json:"cpu_percent"`
+	CPUUserPercent     float64           `json:"cpu_user_percent"`
+	CPUSystemPercent   float64           `json:"cpu_system_percent"`
+	CPUIowaitPercent   float64           `json:"cpu_iowait_percent"` // idle while waiting on disk
+	CPUStealPercent    float64           `json:"cpu_steal_percent"`  // taken by the hypervisor
+	CPUIdlePercent     float64           `json:"cpu_idle_percent"`
+	PerCPUPercent      []float64         `json:"per_cpu_percent,omitempty"`
+	MemUsedMB          uint64            `json:"mem_used_mb"`
+	MemTotalMB         uint64            `json:"mem_total_mb"`
+	MemAvailableMB     uint64            `json:"mem_available_mb"` // what can be allocated without swapping, counting reclaimable cache
+	MemCachedMB        uint64            `json:"mem_cached_mb"`
+	MemBuffersMB       uint64            `json:"mem_buffers_mb"`
+	HugePagesTotal     uint64            `json:"hugepages_total"` // pages in the preallocated hugetlb pool; 0 when none is configured
+	HugePagesFree      uint64            `json:"hugepages_free"`
+	HugePagesRsvd      uint64            `json:"hugepages_rsvd"`      // promised to mappings but not yet faulted in
+	AnonHugePagesMB    uint64            `json:"anon_hugepages_mb"`   // transparent huge pages backing anonymous memory
+	SlabMB             uint64            `json:"slab_mb"`             // kernel slab caches (dentries, inodes, ...)
+	SlabReclaimableMB  uint64            `json:"slab_reclaimable_mb"` // slab the kernel can free under pressure
+	SlabUnreclaimMB    uint64            `json:"slab_unreclaim_mb"`   // steady growth here points at a kernel memory leak
+	SwapUsedMB         uint64            `json:"swap_used_mb"`
+	SwapTotalMB        uint64            `json:"swap_total_mb"`
+	Pswpin             uint64            `json:"pswpin"`
+	Pswpout            uint64            `json:"pswpout"`
+	Pgfault            uint64            `json:"pgfault"`
+	Pgmajfault         uint64            `json:"pgmajfault"`
+	Pgpgin             uint64            `json:"pgpgin"`
+	Pgpgout            uint64            `json:"pgpgout"`
+	NrDirty            uint64            `json:"nr_dirty"`             // pages waiting to be written back; a gauge, not a rate
+	NrWriteback        uint64            `json:"nr_writeback"`         // pages being written back right now
+	SwapPressure       uint64            `json:"swap_pressure"`        // pages swapped in plus out per second
+	SwapLevel          string            `json:"swap_level,omitempty"` // "ok", "elevated" or "thrashing", from SwapPressure
+	ContextSwitches    uint64            `json:"context_switches"`     // per second, from /proc/stat
+	Interrupts         uint64            `json:"interrupts"`           // per second, from /proc/stat
+	ProcsRunning       uint64            `json:"procs_running"`        // runnable tasks right now, from /proc/stat
+	ProcsBlocked       uint64            `json:"procs_blocked"`        // tasks blocked on IO right now
+	DiskReadB          uint64            `json:"disk_read_b" stat:"counter"`
+	DiskWriteB         uint64            `json:"disk_write_b" stat:"counter"`
+	DiskReadBps        uint64            `json:"disk_read_bps"`
+	DiskWriteBps       uint64            `json:"disk_write_bps"`
+	Load1              float64           `json:"load1"`
+	Load5              float64           `json:"load5"`
+	Load15             float64           `json:"load15"`
+	NetRxBytes         uint64            `json:"net_rx_bytes" stat:"counter"`
+	NetTxBytes         uint64            `json:"net_tx_bytes" stat:"counter"`
+	NetRxPackets       uint64            `json:"net_rx_packets" stat:"counter"`
+	NetTxPackets       uint64            `json:"net_tx_packets" stat:"counter"`
+	NetRxErrs          uint64            `json:"net_rx_errs" stat:"counter"`
+	NetTxErrs          uint64            `json:"net_tx_errs" stat:"counter"`
+	NetRxDrop          uint64            `json:"net_rx_drop" stat:"counter"`
+	NetTxDrop          uint64            `json:"net_tx_drop" stat:"counter"`
+	NetRxBps           uint64            `json:"net_rx_bps"`
+	NetTxBps           uint64            `json:"net_tx_bps"`
+	CPUPressureSome10  float64           `json:"cpu_pressure_some10"`
+	CPUPressureSome60  float64           `json:"cpu_pressure_some60"`
+	CPUPressureSome300 float64           `json:"cpu_pressure_some300"`
+	CPUPressureFull10  float64           `json:"cpu_pressure_full10"`
+	CPUPressureFull60  float64           `json:"cpu_pressure_full60"`
+	CPUPressureFull300 float64           `json:"cpu_pressure_full300"`
+	MemPressureSome10  float64           `json:"mem_pressure_some10"`
+	MemPressureSome60  float64           `json:"mem_pressure_some60"`
+	MemPressureSome300 float64           `json:"mem_pressure_some300"`
+	MemPressureFull10  float64           `json:"mem_pressure_full10"`
+	MemPressureFull60  float64           `json:"mem_pressure_full60"`
+	MemPressureFull300 float64           `json:"mem_pressure_full300"`
+	IOPressureSome10   float64           `json:"io_pressure_some10"`
+	IOPressureSome60   float64           `json:"io_pressure_some60"`
+	IOPressureSome300  float64           `json:"io_pressure_some300"`
+	IOPressureFull10   float64           `json:"io_pressure_full10"`
+	IOPressureFull60   float64           `json:"io_pressure_full60"`
+	IOPressureFull300  float64           `json:"io_pressure_full300"`
+	OpenFDs            uint64            `json:"open_fds"`
+	MaxFDs             uint64            `json:"max_fds"`
+	SocketsUsed        uint64            `json:"sockets_used"`
+	ConntrackCount     uint64            `json:"conntrack_count"` // 0 when nf_conntrack is not loaded
+	ConntrackMax       uint64            `json:"conntrack_max"`
+	TCPInSegs          uint64            `json:"tcp_in_segs"`       // per second
+	TCPOutSegs         uint64            `json:"tcp_out_segs"`      // per second
+	TCPRetransSegs     uint64            `json:"tcp_retrans_segs"`  // per second
+	EntropyAvail       uint64            `json:"entropy_avail"`     // bits in the kernel random pool
+	EntropyPoolSize    uint64            `json:"entropy_pool_size"` // bits the pool holds when full
+	PerNet             map[string]NetIO  `json:"per_net,omitempty"`
+	PerDisk            map[string]DiskIO `json:"per_disk,omitempty"`
+	CounterReset       bool              `json:"counter_reset,omitempty"`     // a rate counter reset this interval; its rate reads 0
+	FailedCollectors   []string          `json:"failed_collectors,omitempty"` // sources that could not be read; their fields are zero or stale
+}
+
+// DiskIO is the cumulative IO of a single block device, and how busy it
+// was over the last interval. The aggregate disk counters leave the latter
+// zero.
+type DiskIO struct {
+	ReadB        uint64  `json:"read_b"`
+	WriteB       uint64  `json:"write_b"`
+	ReadCount    uint64  `json:"read_count"`
+	WriteCount   uint64  `json:"write_count"`
+	IOTimeMs     uint64  `json:"io_time_ms"`     // time the device had IO in flight
+	WeightedIOMs uint64  `json:"weighted_io_ms"` // io_time_ms weighted by the number of IOs in flight
+	InFlight     uint64  `json:"in_flight"`      // IOs issued but not yet completed
+	UtilPercent  float64 `json:"util_percent"`   // share of the interval the device was busy; near 100 means saturated
+	AwaitMs      float64 `json:"await_ms"`       // average time an IO completed this interval took, queueing included
+	QueueDepth   float64 `json:"queue_depth"`    // average IOs in flight over the interval
+	rwTimeMs     uint64  // time spent on reads plus writes, from which AwaitMs is derived
+}
+
+// NetIO is the cumulative IO of a single network interface.
+type NetIO struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxErrs    uint64 `json:"rx_errs"`
+	TxErrs    uint64 `json:"tx_errs"`
+	RxDrop    uint64 `json:"rx_drop"`
+	TxDrop    uint64 `json:"tx_drop"`
 }
 
 // Event is a generic event from a tracer.
 type Event map[string]interface{}
 
+// ring is a fixed-capacity circular buffer that overwrites its oldest
+// element once full.
 type ring[T any] struct {
 	mu   sync.RWMutex
-	data []T
+	data []T    // fixed backing slice, len == capacity
+	head int    // index of the oldest element
+	n    int    // number of valid elements
+	seq  uint64 // sequence number of the newest element; the first append is 1
+
+	// Optional byte budget; see withByteBudget.
+	size     func(T) int
+	sizes    []int // estimated size of each element, parallel to data
+	bytes    int   // sum of the valid elements' sizes
+	maxBytes int
 }
 
-// newRing creates a new ring buffer of type T with capacity for historySeconds elements.
-func newRing[T any]() *ring[T] { return &ring[T]{data: make([]T, 0, historySeconds)} }
-func (r *ring[T]) append(v T) {
+// newRing creates a new ring buffer of type T with capacity for n elements.
+func newRing[T any](n int) *ring[T] { return &ring[T]{data: make([]T, n)} }
+
+// withByteBudget also evicts the oldest elements once the estimated total
+// size, per size at append time, would exceed maxBytes, for element types
+// whose size varies too much for the count alone to bound memory. The
+// newest element is always kept, even if it alone exceeds the budget.
+func (r *ring[T]) withByteBudget(maxBytes int, size func(T) int) *ring[T] {
+	r.maxBytes, r.size, r.sizes = maxBytes, size, make([]int, len(r.data))
+	return r
+}
+
+// append adds v, overwriting the oldest element when full, and returns v's
+// sequence number.
+func (r *ring[T]) append(v T) uint64 {
+	sz := 0
+	if r.size != nil {
+		sz = r.size(v) // outside the lock; it may marshal v
+	}
 	r.mu.Lock()
-	if len(r.data) >= historySeconds {
-		r.data = r.data[1:]
+	defer r.mu.Unlock()
+	if r.n == len(r.data) {
+		r.evictOldest()
 	}
-	r.data = append(r.data, v)
-	r.mu.Unlock()
+	for r.size != nil && r.n > 0 && r.bytes+sz > r.maxBytes {
+		r.evictOldest()
+	}
+	i := (r.head + r.n) % len(r.data)
+	r.data[i] = v
+	r.n++
+	if r.size != nil {
+		r.sizes[i] = sz
+		r.bytes += sz
+	}
+	r.seq++
+	return r.seq
+}
+
+// evictOldest drops the oldest element. r.mu must be held.
+func (r *ring[T]) evictOldest() {
+	var zero T
+	r.data[r.head] = zero // let it be collected
+	if r.size != nil {
+		r.bytes -= r.sizes[r.head]
+	}
+	r.head = (r.head + 1) % len(r.data)
+	r.n--
+}
+
+// usedBytes returns the estimated size of the retained elements, or 0
+// without a byte budget.
+func (r *ring[T]) usedBytes() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.bytes
 }
+
+// latest returns the most recently appended element, if any.
+func (r *ring[T]) latest() (T, bool) {
+	v, _, ok := r.latestSeq()
+	return v, ok
+}
+
+// latestSeq is latest that also returns the element's sequence number.
+func (r *ring[T]) latestSeq() (T, uint64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.n == 0 {
+		var zero T
+		return zero, 0, false
+	}
+	return r.data[(r.head+r.n-1)%len(r.data)], r.seq, true
+}
+
+// since returns the retained elements with sequence numbers above after,
+// oldest first, and the sequence number of the first one returned.
+func (r *ring[T]) since(after uint64) ([]T, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	first := r.seq - uint64(r.n) + 1 // sequence number of the oldest element
+	skip := 0
+	if after >= first {
+		skip = int(min(after-first+1, uint64(r.n)))
+	}
+	out := make([]T, 0, r.n-skip)
+	for i := skip; i < r.n; i++ {
+		out = append(out, r.data[(r.head+i)%len(r.data)])
+	}
+	return out, first + uint64(skip)
+}
+
+// snapshot returns a copy of the buffered elements, oldest first.
 func (r *ring[T]) snapshot() []T {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	out := make([]T, len(r.data))
-	copy(out, r.data)
+	out := make([]T, r.n)
+	k := copy(out, r.data[r.head:min(r.head+r.n, len(r.data))])
+	copy(out[k:], r.data[:r.n-k])
 	return out
 }
 
+// The history rings are sized from flags, so they are created in main.
 var (
-	nodeHist = newRing[NodeVmstat]()
-	ctrEvts  = newRing[Event]()
+	nodeHist *ring[NodeVmstat]
+	ctrEvts  *ring[Event]
 )
 
-type vmstatSnapshot struct{ vals map[string]uint64 }
+// parseFlags parses the command line and derives the ring capacity from the
+// history window and sample interval.
+func parseFlags() error {
+	flag.DurationVar(&sampleInterval, "interval", sampleInterval, "node sampling interval")
+	flag.DurationVar(&historyWindow, "history", historyWindow, "how much history to retain")
+	flag.IntVar(&eventHistory, "event-history", eventHistory, "maximum number of events to retain")
+	flag.IntVar(&eventBytes, "event-history-bytes", 0, "also evict the oldest events once the retained events' JSON size exceeds this many bytes (0 disables)")
+	flag.StringVar(&queryAddr, "query-addr", queryAddr, "listen address for the query API, e.g. 127.0.0.1:3100, [::1]:3100 or unix:/run/konverse.sock")
+	flag.StringVar(&ingestAddr, "ingest-addr", ingestAddr, "listen address for the event ingest API, a host:port or unix:/path")
+	flag.StringVar(&grpcAddr, "grpc-addr", "", "listen address for the gRPC streaming API, e.g. 127.0.0.1:3102 (default off)")
+	netIfaces := flag.String("net-interfaces", "", "comma-separated network interfaces to collect (default all)")
+	flag.BoolVar(&perInterface, "per-interface", false, "include per-interface network counters in snapshots")
+	diskRe := flag.String("disk-include", "", "regexp of block devices to collect (default all)")
+	diskExcludeRe := flag.String("disk-exclude", defaultDiskExclude, "regexp of block devices to drop, applied after -disk-include (empty keeps all)")
+	flag.BoolVar(&diskSkipPartitions, "disk-skip-partitions", diskSkipPartitions, "exclude partitions from the disk totals when their parent disk is present; set false if the name heuristic misfires")
+	flag.DurationVar(&sseKeepalive, "sse-keepalive", 0, "send an SSE keepalive comment after this much idle time (default the sample interval)")
+	flag.IntVar(&maxStreams, "max-streams", maxStreams, "maximum concurrent /stream connections (0 for unlimited)")
+	flag.DurationVar(&streamWriteTimeout, "stream-write-timeout", streamWriteTimeout, "disconnect /stream clients that take longer than this to absorb a frame")
+	flag.StringVar(&stateFile, "state-file", "", "persist history to this file and reload it on startup")
+	flag.DurationVar(&stateInterval, "state-interval", stateInterval, "how often to save history to -state-file")
+	flag.StringVar(&ingestToken, "ingest-token", "", "require this bearer token on the ingest API")
+	flag.Int64Var(&maxBodyBytes, "max-body", maxBodyBytes, "maximum ingest request body size in bytes")
+	flag.IntVar(&maxBatchSize, "max-batch", maxBatchSize, "maximum number of events in one /events/batch request")
+	flag.IntVar(&topProcesses, "top-processes", 0, "collect the top N processes by RSS and by CPU each interval (0 disables)")
+	flag.DurationVar(&fsInterval, "fs-interval", fsInterval, "how often to sample filesystem usage (0 disables)")
+	flag.DurationVar(&thermalInterval, "thermal-interval", 0, "how often to read temperature sensors and CPU throttle counts, e.g. 30s (default off)")
+	flag.StringVar(&remoteWriteURL, "remote-write", "", "POST metrics in OpenMetrics text format to this URL (default off)")
+	flag.DurationVar(&remoteWriteInterval, "remote-write-interval", remoteWriteInterval, "how often to push metrics to -remote-write")
+	flag.StringVar(&graphiteAddr, "graphite", "", "send each stats sample to this Graphite plaintext listener, e.g. graphite:2003 (default off)")
+	flag.StringVar(&graphitePrefix, "graphite-prefix", graphitePrefix, "metric path prefix for -graphite; {node} is replaced by the node name")
+	flag.BoolVar(&pprofEnabled, "pprof", false, "serve Go profiling endpoints under /debug/pprof/ on the query API (default off)")
+	peers := flag.String("aggregate", "", "comma-separated query API addresses of other collectors to serve a fleet view of, e.g. host1:3100,host2:3100")
+	flag.DurationVar(&aggregateInterval, "aggregate-interval", aggregateInterval, "how often to check that -aggregate peers are reachable")
+	flag.StringVar(&eventLogPath, "event-log", "", "append every accepted event to this JSON-lines file, served by /events/replay (default off)")
+	flag.Int64Var(&eventLogMaxSize, "event-log-max-size", eventLogMaxSize, "rotate -event-log once it exceeds this many bytes")
+	flag.IntVar(&eventLogKeep, "event-log-keep", eventLogKeep, "number of rotated -event-log files to keep")
+	flag.DurationVar(&containerInterval, "container-interval", containerInterval, "how often to read container cgroup stats (0 disables)")
+	flag.StringVar(&cgroupRoot, "cgroup-root", cgroupRoot, "cgroup v2 mount point, for resolving relative container cgroup paths")
+	cgroups := flag.String("container-cgroups", "", "comma-separated container cgroup paths to collect besides those discovered from events")
+	flag.Func("rule", `emit a threshold event when a stat crosses a limit, e.g. "cpu_percent>90 for 10s" (repeatable)`, func(v string) error {
+		r, err := parseRule(v)
+		if err != nil {
+			return err
+		}
+		thresholdRules = append(thresholdRules, r)
+		return nil
+	})
+	flag.StringVar(&natsURL, "nats-url", "", "publish every accepted event as JSON to this NATS server, e.g. nats://127.0.0.1:4222 (default off)")
+	flag.StringVar(&natsSubject, "nats-subject", natsSubject, "NATS subject events are published to")
+	flag.IntVar(&natsBufferLen, "nats-buffer", natsBufferLen, "bytes of events buffered while NATS is unreachable")
+	flag.DurationVar(&longHistory, "long-history", longHistory, "how long to keep the downsampled long-term stats tier (0 disables)")
+	flag.DurationVar(&longResolution, "long-resolution", longResolution, "bucket size of the long-term stats tier")
+	fsTypeList := flag.String("fs-types", "", "comma-separated filesystem types to sample, e.g. ext4,xfs (default physical filesystems)")
+	disable := flag.String("disable", "", "comma-separated collectors to skip, e.g. disk,net")
+	flag.Uint64Var(&swapElevatedRate, "swap-elevated-rate", swapElevatedRate, "pages swapped in+out per second at which swap_level is elevated")
+	flag.Uint64Var(&swapThrashingRate, "swap-thrashing-rate", swapThrashingRate, "pages swapped in+out per second at which swap_level is thrashing")
+	flag.StringVar(&nodeName, "node-name", "", "name identifying this node in enveloped responses (default the hostname)")
+	labels := flag.String("node-labels", "", "comma-separated key=value labels identifying this node, e.g. zone=us-east1-b,pool=default")
+	origins := flag.String("allow-origin", "", "comma-separated origins allowed to call the query API from a browser, or * for any (default off)")
+	levelName := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	flag.StringVar(&jsonStyle, "json-style", jsonStyle, "key naming of the query API's JSON and msgpack documents: snake or camel")
+	flag.StringVar(&configPath, "config", "", "load flag values from this YAML or JSON file; flags given on the command line take precedence")
+	flag.Parse()
+	if configPath != "" {
+		if err := applyConfigFile(configPath); err != nil {
+			return fmt.Errorf("-config: %w", err)
+		}
+	}
+
+	if err := logLevel.UnmarshalText([]byte(*levelName)); err != nil {
+		return fmt.Errorf("-log-level: %w", err)
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
+	if jsonStyle != "snake" && jsonStyle != "camel" {
+		return fmt.Errorf("-json-style must be snake or camel, got %q", jsonStyle)
+	}
+	if maxBodyBytes < 1 {
+		return fmt.Errorf("-max-body must be positive, got %d", maxBodyBytes)
+	}
+	if maxBatchSize < 1 {
+		return fmt.Errorf("-max-batch must be at least 1, got %d", maxBatchSize)
+	}
+	if topProcesses < 0 {
+		return fmt.Errorf("-top-processes must not be negative, got %d", topProcesses)
+	}
+	if stateFile != "" && stateInterval <= 0 {
+		return fmt.Errorf("-state-interval must be positive, got %v", stateInterval)
+	}
+	if swapThrashingRate < swapElevatedRate {
+		return fmt.Errorf("-swap-thrashing-rate (%d) must not be below -swap-elevated-rate (%d)", swapThrashingRate, swapElevatedRate)
+	}
+	if streamWriteTimeout <= 0 {
+		return fmt.Errorf("-stream-write-timeout must be positive, got %v", streamWriteTimeout)
+	}
+	if sseKeepalive <= 0 {
+		sseKeepalive = sampleInterval
+	}
+	if *diskRe != "" {
+		re, err := regexp.Compile(*diskRe)
+		if err != nil {
+			return fmt.Errorf("-disk-include: %w", err)
+		}
+		diskInclude = re
+	}
+	if *diskExcludeRe != "" {
+		re, err := regexp.Compile(*diskExcludeRe)
+		if err != nil {
+			return fmt.Errorf("-disk-exclude: %w", err)
+		}
+		diskExclude = re
+	}
+
+	if err := disableCollectors(splitList(*disable)); err != nil {
+		return fmt.Errorf("-disable: %w", err)
+	}
+	slog.Info("collectors enabled", "collectors", collectorNames(enabledCollectors()))
+	slog.Info("disk filter", "include", *diskRe, "exclude", *diskExcludeRe, "skip_partitions", diskSkipPartitions)
+
+	if nodeName == "" {
+		var err error
+		if nodeName, err = os.Hostname(); err != nil {
+			return fmt.Errorf("-node-name not set and hostname unavailable: %w", err)
+		}
+	}
+	for _, kv := range splitList(*labels) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("-node-labels: %q is not key=value", kv)
+		}
+		if nodeLabels == nil {
+			nodeLabels = map[string]string{}
+		}
+		nodeLabels[k] = v
+	}
+
+	for _, o := range splitList(*origins) {
+		if allowOrigins == nil {
+			allowOrigins = map[string]bool{}
+		}
+		allowOrigins[o] = true
+	}
+
+	if remoteWriteURL != "" && remoteWriteInterval <= 0 {
+		return fmt.Errorf("-remote-write-interval must be positive, got %v", remoteWriteInterval)
+	}
+	if graphiteAddr != "" && (graphitePrefix == "" || strings.ContainsAny(graphitePrefix, " \t\n")) {
+		return fmt.Errorf("-graphite-prefix must be non-empty without whitespace, got %q", graphitePrefix)
+	}
+	for _, addr := range splitList(*peers) {
+		aggregatePeers = append(aggregatePeers, newPeer(addr))
+	}
+	if len(aggregatePeers) > 0 && aggregateInterval <= 0 {
+		return fmt.Errorf("-aggregate-interval must be positive, got %v", aggregateInterval)
+	}
+	if eventLogMaxSize < 1 {
+		return fmt.Errorf("-event-log-max-size must be positive, got %d", eventLogMaxSize)
+	}
+	if eventLogKeep < 0 {
+		return fmt.Errorf("-event-log-keep must not be negative, got %d", eventLogKeep)
+	}
+	if natsURL != "" && natsSubject == "" {
+		return fmt.Errorf("-nats-subject must not be empty")
+	}
+	if longHistory < 0 {
+		return fmt.Errorf("-long-history must not be negative, got %v", longHistory)
+	}
+	if containerInterval < 0 {
+		return fmt.Errorf("-container-interval must not be negative, got %v", containerInterval)
+	}
+	containerCgroups = splitList(*cgroups)
+	for _, p := range containerCgroups {
+		if _, ok := cgroupDir(p); !ok {
+			return fmt.Errorf("-container-cgroups: %q is outside -cgroup-root %s", p, cgroupRoot)
+		}
+	}
+	if fsInterval < 0 {
+		return fmt.Errorf("-fs-interval must not be negative, got %v", fsInterval)
+	}
+	if thermalInterval < 0 {
+		return fmt.Errorf("-thermal-interval must not be negative, got %v", thermalInterval)
+	}
+	if names := splitList(*fsTypeList); len(names) > 0 {
+		fsTypes = map[string]bool{}
+		for _, n := range names {
+			fsTypes[n] = true
+		}
+	}
+
+	if names := splitList(*netIfaces); len(names) > 0 {
+		netInterfaces = map[string]bool{}
+		for _, n := range names {
+			netInterfaces[n] = true
+		}
+	}
+
+	if sampleInterval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %v", sampleInterval)
+	}
+	if longHistory > 0 && (longResolution < sampleInterval || longResolution%sampleInterval != 0) {
+		return fmt.Errorf("-long-resolution must be a multiple of -interval, got %v", longResolution)
+	}
+	if historyWindow <= 0 {
+		return fmt.Errorf("-history must be positive, got %v", historyWindow)
+	}
+	if eventHistory < 1 {
+		return fmt.Errorf("-event-history must be at least 1, got %d", eventHistory)
+	}
+	if eventBytes < 0 {
+		return fmt.Errorf("-event-history-bytes must not be negative, got %d", eventBytes)
+	}
+	historySize = int(historyWindow / sampleInterval)
+	if historyWindow%sampleInterval != 0 {
+		// Round up so the ring always covers at least the requested window.
+		historySize++
+		slog.Warn("-history is not a multiple of -interval, rounding up", "history", historyWindow.String(), "interval", sampleInterval.String())
+	}
+	if historySize < 1 {
+		return errors.New("history window must hold at least one sample")
+	}
+	slog.Info("sampling", "interval", sampleInterval.String(), "samples", historySize, "window", (time.Duration(historySize) * sampleInterval).String())
+	logEffectiveConfig()
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// vmstatSnapshot is a set of cumulative counters and when they were read.
+type vmstatSnapshot struct {
+	vals map[string]uint64
+	at   time.Time
+}
 
 func readProcVmstat() (vmstatSnapshot, error) {
 	f, err := os.Open("/proc/vmstat")
@@ -90,19 +583,183 @@ func readProcVmstat() (vmstatSnapshot, error) {
 			m[fs[0]] = n
 		}
 	}
-	return vmstatSnapshot{vals: m}, sc.Err()
+	return vmstatSnapshot{vals: m, at: time.Now()}, sc.Err()
+}
+
+// readProcStat reads the context switch and interrupt counters and the
+// procs_running and procs_blocked gauges from /proc/stat as a
+// vmstatSnapshot keyed by their names there.
+func readProcStat() (vmstatSnapshot, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return vmstatSnapshot{}, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20) // the intr line lists every IRQ and can be long
+	m := map[string]uint64{}
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) < 2 {
+			continue
+		}
+		switch fs[0] {
+		case "ctxt", "intr", "procs_running", "procs_blocked":
+		default:
+			continue
+		}
+		// intr is followed by per-IRQ counts; the first value is the total.
+		if n, err := strconv.ParseUint(fs[1], 10, 64); err == nil {
+			m[fs[0]] = n
+		}
+	}
+	return vmstatSnapshot{vals: m, at: time.Now()}, sc.Err()
 }
 
-func deltaPerSec(prev, cur vmstatSnapshot, key string, secs float64) uint64 {
+// deltaPerSec returns the per-second rate of counter key between two
+// snapshots, over the time that actually passed between the two reads, so a
+// slow or descheduled loop doesn't inflate the rate. A counter that went
+// backwards is treated as a reset (device re-added, driver reload,
+// wraparound): the rate for that interval is 0 and reset is true so callers
+// can flag the sample instead of charting a spike.
+func deltaPerSec(prev, cur vmstatSnapshot, key string) (rate uint64, reset bool) {
+	secs := cur.at.Sub(prev.at).Seconds()
 	if secs <= 0 {
-		return 0
+		return 0, false
 	}
 	a, ok1 := prev.vals[key]
 	b, ok2 := cur.vals[key]
-	if !ok1 || !ok2 || b < a {
-		return 0
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	if b < a {
+		return 0, true
+	}
+	return uint64(float64(b-a)/secs + 0.5), false
+}
+
+// defaultDiskExclude drops loop devices (one per snap), RAM disks and
+// device-mapper volumes, whose IO is either synthetic or already counted on
+// the disks beneath them.
+const defaultDiskExclude = `^(loop|ram|dm-)`
+
+// readDiskIO returns the allowed block devices' counters and their sum.
+func readDiskIO() (DiskIO, map[string]DiskIO, error) {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return DiskIO{}, nil, err
+	}
+	per := map[string]DiskIO{}
+	for name, c := range counters {
+		if diskInclude != nil && !diskInclude.MatchString(name) {
+			continue
+		}
+		if diskExclude != nil && diskExclude.MatchString(name) {
+			continue
+		}
+		per[name] = DiskIO{
+			ReadB: c.ReadBytes, WriteB: c.WriteBytes,
+			ReadCount: c.ReadCount, WriteCount: c.WriteCount,
+			IOTimeMs: c.IoTime, WeightedIOMs: c.WeightedIO, InFlight: c.IopsInProgress,
+			rwTimeMs: c.ReadTime + c.WriteTime,
+		}
+	}
+	return diskTotal(per), per, nil
+}
+
+// diskTotal sums per, leaving out partitions whose parent disk is also
+// present when diskSkipPartitions is set, so IO isn't counted twice.
+func diskTotal(per map[string]DiskIO) DiskIO {
+	var total DiskIO
+	for name, io := range per {
+		if diskSkipPartitions {
+			if _, ok := partitionParent(name, per); ok {
+				continue
+			}
+		}
+		total.ReadB += io.ReadB
+		total.WriteB += io.WriteB
+		total.ReadCount += io.ReadCount
+		total.WriteCount += io.WriteCount
+	}
+	return total
+}
+
+// diskLatency sets cur's utilization, average wait and queue depth from
+// the change in its counters since prev, dt earlier. It reports false,
+// leaving them zero, when a counter went backwards: the device was
+// replaced, or a 32-bit time counter wrapped.
+func diskLatency(prev DiskIO, cur *DiskIO, dt time.Duration) bool {
+	ios, prevIOs := cur.ReadCount+cur.WriteCount, prev.ReadCount+prev.WriteCount
+	if cur.IOTimeMs < prev.IOTimeMs || cur.WeightedIOMs < prev.WeightedIOMs || cur.rwTimeMs < prev.rwTimeMs || ios < prevIOs {
+		return false
+	}
+	ms := float64(dt) / float64(time.Millisecond)
+	if ms <= 0 {
+		return true
+	}
+	// io_time can run slightly ahead of the wall clock between reads.
+	cur.UtilPercent = min(float64(cur.IOTimeMs-prev.IOTimeMs)/ms*100, 100)
+	cur.QueueDepth = float64(cur.WeightedIOMs-prev.WeightedIOMs) / ms
+	if ios > prevIOs {
+		cur.AwaitMs = float64(cur.rwTimeMs-prev.rwTimeMs) / float64(ios-prevIOs)
+	}
+	return true
+}
+
+// partitionParent reports the whole-disk device that name is a partition of,
+// if that device is present in devs. It recognizes sda1 -> sda as well as
+// the "p" separator used by nvme0n1p1 -> nvme0n1 and mmcblk0p1 -> mmcblk0.
+func partitionParent[V any](name string, devs map[string]V) (string, bool) {
+	base := strings.TrimRight(name, "0123456789")
+	if base == name || base == "" {
+		return "", false
+	}
+	if _, ok := devs[base]; ok {
+		return base, true
+	}
+	if p := strings.TrimSuffix(base, "p"); p != base && p != "" && p[len(p)-1] >= '0' && p[len(p)-1] <= '9' {
+		if _, ok := devs[p]; ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// readNetIO sums the counters of the allowed interfaces. The totals are also
+// returned as a vmstatSnapshot so rates can be derived with deltaPerSec.
+func readNetIO() (NetIO, map[string]NetIO, vmstatSnapshot, error) {
+	counters, err := psnet.IOCounters(true)
+	if err != nil {
+		return NetIO{}, nil, vmstatSnapshot{}, err
 	}
-	return uint64(float64(b-a)/secs + 0.5)
+	var total NetIO
+	per := map[string]NetIO{}
+	for _, c := range counters {
+		if netInterfaces != nil && !netInterfaces[c.Name] {
+			continue
+		}
+		io := NetIO{
+			RxBytes: c.BytesRecv, TxBytes: c.BytesSent,
+			RxPackets: c.PacketsRecv, TxPackets: c.PacketsSent,
+			RxErrs: c.Errin, TxErrs: c.Errout,
+			RxDrop: c.Dropin, TxDrop: c.Dropout,
+		}
+		per[c.Name] = io
+		total.RxBytes += io.RxBytes
+		total.TxBytes += io.TxBytes
+		total.RxPackets += io.RxPackets
+		total.TxPackets += io.TxPackets
+		total.RxErrs += io.RxErrs
+		total.TxErrs += io.TxErrs
+		total.RxDrop += io.RxDrop
+		total.TxDrop += io.TxDrop
+	}
+	snap := vmstatSnapshot{vals: map[string]uint64{
+		"rx_bytes": total.RxBytes,
+		"tx_bytes": total.TxBytes,
+	}, at: time.Now()}
+	return total, per, snap, nil
 }
 
 func readUint(p string) (uint64, error) {
@@ -117,158 +774,712 @@ func readUint(p string) (uint64, error) {
 	return strconv.ParseUint(s, 10, 64)
 }
 
-func collectNodeLoop() {
-	var prevVM vmstatSnapshot
-	var havePrev bool
+// ignoreNotExist drops "file does not exist" errors, for optional /proc and
+// /sys files whose absence just means the metric is unavailable.
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
 
+// superviseNodeLoop runs collectNodeLoop until ctx is done, restarting it if
+// it ever exits early, e.g. from a panic outside a collector, so the agent
+// never silently serves stale data.
+func superviseNodeLoop(ctx context.Context) {
+	for {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					slog.Error("collection loop panicked", "panic", p, "stack", string(debug.Stack()))
+				}
+			}()
+			collectNodeLoop(ctx)
+		}()
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("collection loop exited, restarting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sampleInterval):
+		}
+	}
+}
+
+// collectNodeLoop samples node stats every sampleInterval until ctx is done.
+// Each registered Collector is read in turn and its output merged into one
+// snapshot.
+func collectNodeLoop(ctx context.Context) {
+	next := time.Now()
 	for {
 		start := time.Now()
-		// CPU/mem/swap
-		cpuPct, _ := cpu.Percent(0, false)
-		vm, _ := mem.VirtualMemory()
-		sw, _ := mem.SwapMemory()
-		// Disk cumulative
-		dio, _ := disk.IOCounters()
-		var rb, wb uint64
-		for _, v := range dio {
-			rb += v.ReadBytes
-			wb += v.WriteBytes
-		}
-
-		// /proc/vmstat deltas
-		curVM, _ := readProcVmstat()
-		var psin, psout, pf, pmf, pgin, pgout uint64
-		if havePrev {
-			secs := sampleInterval.Seconds()
-			psin = deltaPerSec(prevVM, curVM, "pswpin", secs)
-			psout = deltaPerSec(prevVM, curVM, "pswpout", secs)
-			pf = deltaPerSec(prevVM, curVM, "pgfault", secs)
-			pmf = deltaPerSec(prevVM, curVM, "pgmajfault", secs)
-			pgin = deltaPerSec(prevVM, curVM, "pgpgin", secs)
-			pgout = deltaPerSec(prevVM, curVM, "pgpgout", secs)
-		}
-		prevVM, havePrev = curVM, true
-
-		nodeHist.append(NodeVmstat{
-			TS:          time.Now(),
-			CPUPercent:  cpuPct[0],
-			MemUsedMB:   vm.Used / (1024 * 1024),
-			MemTotalMB:  vm.Total / (1024 * 1024),
-			SwapUsedMB:  sw.Used / (1024 * 1024),
-			SwapTotalMB: sw.Total / (1024 * 1024),
-			Pswpin:      psin, Pswpout: psout,
-			Pgfault: pf, Pgmajfault: pmf, Pgpgin: pgin, Pgpgout: pgout,
-			DiskReadB: rb, DiskWriteB: wb,
-		})
+		// failed lists the collectors whose read failed this interval, so a
+		// zero in the snapshot can be told apart from a missing read.
+		var failed []string
+		var snap NodeVmstat
+		for _, c := range enabledCollectors() {
+			out, err := collect(ctx, c)
+			for k, v := range out {
+				if err := setKey(&snap, k, v); err != nil {
+					slog.Error("merging collector output", "collector", c.Name(), "err", err)
+				}
+			}
+			if collectorErrs.record(c.Name(), err) {
+				failed = append(failed, c.Name())
+			}
+		}
+		// Stamp the instant sampling began, not when it finished, so TS
+		// doesn't drift by however long the collectors took.
+		snap.TS = start
+		snap.FailedCollectors = failed
 
-		if rem := sampleInterval - time.Since(start); rem > 0 {
-			time.Sleep(rem)
+		sampleSelf()
+		publishStats(nodeHist.append(snap), snap)
+		evaluateRules(&snap)
+
+		// Sample on fixed boundaries so the interval doesn't drift with
+		// collection time. After an overrun, skip to the next boundary
+		// rather than firing back-to-back to catch up.
+		next = next.Add(sampleInterval)
+		if late := time.Since(next); late > 0 {
+			skipped := late/sampleInterval + 1
+			next = next.Add(skipped * sampleInterval)
+			collectionOverruns.Add(float64(skipped))
+			slog.Debug("collection overran the interval", "took", time.Since(start).String(), "skipped", int(skipped))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
 		}
 	}
 }
 
-func writeJSON(w http.ResponseWriter, v any) {
+// writeJSON encodes v as JSON, gzip-compressing bodies of at least
+// gzipMinSize bytes when the client accepts it. Output is compact unless the
+// request asks for pretty=1.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+// writeJSONStatus is writeJSON with an explicit status code.
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	b := restyleJSON(buf.Bytes())
+	if wantsPretty(r) {
+		var ind bytes.Buffer
+		json.Indent(&ind, b, "", "  ")
+		b = ind.Bytes()
+	}
 	w.Header().Set("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(v)
+	writeBody(w, r, status, b)
 }
 
-func historyHandler(w http.ResponseWriter, r *http.Request) {
-	scope := r.URL.Query().Get("scope")
-	switch scope {
-	case "", "events":
-		writeJSON(w, ctrEvts.snapshot())
-	case "stats":
-		writeJSON(w, nodeHist.snapshot())
-	default:
-		http.Error(w, "invalid scope", 400)
+// apiError is the JSON body of an error response.
+type apiError struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// writeError replies with status and a JSON apiError body.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSONStatus(w, r, status, apiError{Error: msg, Code: status})
+}
+
+// writeBody writes b, gzip-compressed if it is large enough and the client
+// accepts gzip. Streaming responses must not use it: compression buffers
+// output and breaks per-frame flushing.
+func writeBody(w http.ResponseWriter, r *http.Request, status int, b []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(b) < gzipMinSize || !acceptsGzip(r) {
+		w.WriteHeader(status)
+		w.Write(b)
+		return
 	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(status)
+	zw := gzip.NewWriter(w)
+	zw.Write(b)
+	zw.Close()
 }
 
-func streamHandler(w http.ResponseWriter, r *http.Request) {
-	scope := r.URL.Query().Get("scope")
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "stream unsupported", 500)
+// wantsPretty reports whether the request asks for indented JSON, for
+// humans reading responses with curl. Machine consumers never read the
+// indentation, so it is off by default.
+func wantsPretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// writeMsgpack is writeJSON for clients that negotiated msgpack. Keys come
+// from the json tags, so the document has the same shape as the JSON one;
+// timestamps use the msgpack timestamp extension.
+func writeMsgpack(w http.ResponseWriter, r *http.Request, v any) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		writeError(w, r, 500, fmt.Sprintf("encoding msgpack: %v", err))
+		return
+	}
+	b, err := restyleMsgpack(buf.Bytes())
+	if err != nil {
+		writeError(w, r, 500, fmt.Sprintf("encoding msgpack: %v", err))
 		return
 	}
+	w.Header().Set("Content-Type", msgpackType)
+	writeBody(w, r, http.StatusOK, b)
+}
 
-	t := time.NewTicker(sampleInterval)
-	defer t.Stop()
-	for {
-		select {
-		case <-t.C:
-			var payload []byte
-			switch scope {
-			case "", "events":
-				data := ctrEvts.snapshot()
-				if len(data) > 0 {
-					payload, _ = json.Marshal(data[len(data)-1])
-				}
-			case "stats":
-				data := nodeHist.snapshot()
-				if len(data) > 0 {
-					payload, _ = json.Marshal(data[len(data)-1])
-				}
-			default:
-				continue
+const msgpackType = "application/msgpack"
+
+// acceptsMsgpack reports whether the request's Accept header lists
+// application/msgpack.
+func acceptsMsgpack(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		typ, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(typ) != msgpackType {
+			continue
+		}
+		return !refused(params)
+	}
+	return false
+}
+
+// refused reports whether the parameters of an Accept-style list entry
+// carry a quality of zero (q=0, q=0.0, ...), which refuses it.
+func refused(params string) bool {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(strings.TrimSpace(k), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return err == nil && q == 0
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		return !refused(params)
+	}
+	return false
+}
+
+// historyHandler serves the buffered events or stats. The from/to params
+// filter by timestamp before encoding, so only the matching window is sent.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tr, err := parseTimeRange(q)
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil {
+			writeError(w, r, 400, "invalid limit")
+			return
+		}
+	}
+	env, err := envelopeParam(q)
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" && format != "ndjson" && format != "influx" {
+		writeError(w, r, 400, "invalid format")
+		return
+	}
+	// Taken before reading the history, so a sample landing meanwhile
+	// can only make the ETag stale, never the body.
+	etag := historyETag(r, q)
+	// respond writes v as JSON, NDJSON with format=ndjson, or msgpack when
+	// the client asks for it without naming a format, wrapped with the node
+	// identity on request. It replies 304 instead when the client already
+	// has this version.
+	w.Header().Add("Vary", "Accept")
+	respond := func(v any) {
+		if notModified(w, r, etag) {
+			return
+		}
+		if format == "ndjson" {
+			writeNDJSON(w, r, v, env)
+			return
+		}
+		if env {
+			v = identityEnvelope{Node: nodeName, Labels: nodeLabels, Data: v}
+		}
+		if format == "" && acceptsMsgpack(r) {
+			writeMsgpack(w, r, v)
+			return
+		}
+		writeJSON(w, r, v)
+	}
+	scope := q.Get("scope")
+	if (format == "csv" || format == "influx") && scope != "stats" {
+		writeError(w, r, 400, format+" format is only supported for scope=stats")
+		return
+	}
+	switch scope {
+	case "", "events":
+		evts := filterRange(ctrEvts.snapshot(), tr, eventTime)
+		evts = filterTypes(evts, splitList(q.Get("type")))
+		respond(lastN(evts, limit))
+	case "stats":
+		fields, err := parseFields(q.Get("fields"))
+		if err != nil {
+			writeError(w, r, 400, err.Error())
+			return
+		}
+		var stats []NodeVmstat
+		if res := q.Get("resolution"); res != "" {
+			d, err := time.ParseDuration(res)
+			if err != nil || d <= 0 {
+				writeError(w, r, 400, "invalid resolution")
+				return
+			}
+			agg := q.Get("agg")
+			if agg != "" && !validAgg(agg) {
+				writeError(w, r, 400, "invalid agg: want avg, max, min or last")
+				return
+			}
+			stats = downsampledStats(tr, d, agg)
+		} else if q.Get("agg") != "" {
+			writeError(w, r, 400, "agg requires resolution")
+			return
+		} else {
+			stats = filterRange(nodeHist.snapshot(), tr, statTime)
+		}
+		stats = lastN(stats, limit)
+		if format == "csv" || format == "influx" {
+			cols, err := scalarColumns(fields)
+			if err != nil {
+				writeError(w, r, 400, err.Error())
+				return
 			}
-			if len(payload) > 0 {
-				fmt.Fprintf(w, "data: %s\n\n", string(payload))
-				flusher.Flush()
+			if format == "influx" && len(cols) == 0 {
+				// A line protocol line needs at least one field.
+				writeError(w, r, 400, "influx format needs at least one scalar field besides ts")
+				return
 			}
-		case <-r.Context().Done():
+			if notModified(w, r, etag) {
+				return
+			}
+			if format == "influx" {
+				writeStatsInflux(w, r, stats, cols)
+			} else {
+				writeStatsCSV(w, r, stats, cols)
+			}
+			return
+		}
+		if len(fields) > 0 {
+			respond(project(stats, fields))
+			return
+		}
+		respond(stats)
+	case "processes":
+		procs := filterRange(procHist.snapshot(), tr, processTime)
+		respond(lastN(procs, limit))
+	case "fs":
+		fs := filterRange(fsHist.snapshot(), tr, fsTime)
+		respond(lastN(fs, limit))
+	case "thermal":
+		therm := filterRange(thermalHist.snapshot(), tr, thermalTime)
+		respond(lastN(therm, limit))
+	case "container":
+		id := q.Get("id")
+		if id == "" {
+			writeError(w, r, 400, "scope=container requires id")
 			return
 		}
+		samples, ok := containerSeries(id)
+		if !ok {
+			writeError(w, r, 404, fmt.Sprintf("no stats for container %q", id))
+			return
+		}
+		respond(lastN(filterRange(samples, tr, containerSampleTime), limit))
+	default:
+		writeError(w, r, 400, "invalid scope")
+	}
+}
+
+// ingestAuthorized reports whether r carries the configured ingest token.
+// Without a token the ingest API stays open.
+func ingestAuthorized(r *http.Request) bool {
+	if ingestToken == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(ingestToken)) == 1
+}
+
+// checkIngest enforces the method, token and body size limit shared by the
+// ingest endpoints, replying with an error and returning false if the
+// request is rejected.
+func checkIngest(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		// Enforced here rather than with a "POST /events" mux pattern so
+		// the 405 keeps the JSON error body.
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, 405, "POST only")
+		return false
+	}
+	if !ingestAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, r, 401, "unauthorized")
+		return false
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	return true
+}
+
+// decodeBody decodes the JSON request body into v, replying 413 if it
+// exceeds the limit set by checkIngest and 400 if it is malformed.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooBig *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooBig):
+		writeError(w, r, 413, fmt.Sprintf("request body exceeds %d bytes", tooBig.Limit))
+	default:
+		writeError(w, r, 400, "bad json: "+err.Error())
+	}
+	return false
 }
 
 // eventIngestHandler ingests container lifecycle events from the ebpf tracers.
 func eventIngestHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "POST only", 405)
+	if !checkIngest(w, r) {
 		return
 	}
 	var ev Event
-	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
-		http.Error(w, "bad json: "+err.Error(), 400)
+	if !decodeBody(w, r, &ev) {
 		return
 	}
-	// Set timestamp if missing
-	if _, ok := ev["ts"]; !ok {
-		ev["ts"] = time.Now()
-	}
-	// Expect type
-	if t, ok := ev["type"]; !ok {
-		http.Error(w, "invalid node event ingestion: missing type", 400)
+	if err := prepareEvent(ev); err != nil {
+		writeError(w, r, 400, err.Error())
 		return
-	} else {
-		log.Println("Rx event type: ", t)
 	}
-	ctrEvts.append(ev)
+	storeEvent(ev)
 	w.WriteHeader(204)
 }
 
+// nearestStat returns the buffered sample whose timestamp is closest to t.
+func nearestStat(t time.Time) (NodeVmstat, bool) {
+	stats := nodeHist.snapshot()
+	if len(stats) == 0 {
+		return NodeVmstat{}, false
+	}
+	i := sort.Search(len(stats), func(i int) bool { return !stats[i].TS.Before(t) })
+	if i == len(stats) || (i > 0 && t.Sub(stats[i-1].TS) < stats[i].TS.Sub(t)) {
+		i--
+	}
+	return stats[i], true
+}
+
 func pingHandler(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
 
+// staleIntervals is how many sample intervals may pass without a new sample
+// before /healthz reports the collection loop as stalled.
+const staleIntervals = 3
+
+var startTime = time.Now()
+
+type probeStatus struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// writeProbe replies 200 when reason is empty and 503 with the reason otherwise.
+func writeProbe(w http.ResponseWriter, r *http.Request, reason string) {
+	if reason == "" {
+		writeJSON(w, r, probeStatus{Status: "ok"})
+		return
+	}
+	writeJSONStatus(w, r, 503, probeStatus{Status: "unhealthy", Reason: reason})
+}
+
+// healthzHandler is a liveness probe: it fails once the newest sample (or
+// process start, before the first sample) is more than staleIntervals old.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	last := startTime
+	if s, ok := nodeHist.latest(); ok {
+		last = s.TS
+	}
+	var reason string
+	if age := time.Since(last); age > staleIntervals*sampleInterval {
+		reason = fmt.Sprintf("collection stalled: last sample %v ago", age.Round(time.Millisecond))
+	}
+	writeProbe(w, r, reason)
+}
+
+// readyzHandler is a readiness probe: it fails until the first sample exists.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	var reason string
+	if _, ok := nodeHist.latest(); !ok {
+		reason = "no samples collected yet"
+	}
+	writeProbe(w, r, reason)
+}
+
+// socketMode is the permission of Unix sockets the agent listens on: the
+// owner and its group may connect, so access is granted through group
+// membership rather than an open port.
+const socketMode = 0o660
+
+// listen listens on a TCP address, or on a Unix socket for an address of
+// the form unix:/path. A stale socket left by an unclean exit is replaced,
+// but one that still accepts connections belongs to a running instance and
+// is an error, as is any other file at the path. The socket is removed
+// again when its listener closes.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// fatal logs err with msg and exits.
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"err", err}, args...)...)
+	os.Exit(1)
+}
+
 func main() {
-	go collectNodeLoop()
-	queryMux := http.NewServeMux()
+	if err := parseFlags(); err != nil {
+		fatal("invalid flags", err)
+	}
+	b := currentBuild()
+	slog.Info("starting", "version", b.Version, "commit", b.Commit, "build_date", b.BuildDate, "go_version", b.GoVersion)
+	nodeHist = newRing[NodeVmstat](historySize)
+	ctrEvts = newRing[Event](eventHistory)
+	if eventBytes > 0 {
+		ctrEvts.withByteBudget(eventBytes, func(ev Event) int {
+			b, _ := json.Marshal(ev)
+			return len(b)
+		})
+	}
+	procHist = newRing[ProcessSample](historySize)
+	fsHist = newRing[FSSample](1)
+	thermalHist = newRing[ThermalSample](1)
+	if longHistory > 0 {
+		longHist = newRing[NodeVmstat](int((longHistory + longResolution - 1) / longResolution))
+	}
+	if fsInterval > 0 {
+		fsHist = newRing[FSSample](int((historyWindow + fsInterval - 1) / fsInterval))
+	}
+	if thermalInterval > 0 {
+		thermalHist = newRing[ThermalSample](int((historyWindow + thermalInterval - 1) / thermalInterval))
+	}
+
+	if stateFile != "" {
+		if err := loadState(stateFile); err != nil {
+			slog.Error("restoring state", "path", stateFile, "err", err)
+		}
+	}
+
+	if eventLogPath != "" {
+		l, err := openEventLog(eventLogPath)
+		if err != nil {
+			fatal("opening event log", err, "path", eventLogPath)
+		}
+		evLog = l
+	}
+
+	if natsURL != "" {
+		nc, err := connectNATS()
+		if err != nil {
+			fatal("connecting to nats", err, "url", natsURL)
+		}
+		natsConn = nc
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reloadOnSIGHUP(ctx)
+
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		superviseNodeLoop(ctx)
+	}()
+	if stateFile != "" {
+		go persistLoop(ctx, stateFile, stateInterval)
+	}
+	if topProcesses > 0 {
+		go collectProcessLoop(ctx)
+	}
+	if fsInterval > 0 {
+		go collectFSLoop(ctx)
+	}
+	if thermalInterval > 0 {
+		go collectThermalLoop(ctx)
+	}
+	if containerInterval > 0 {
+		go collectContainersLoop(ctx)
+	}
+	if longHist != nil {
+		go rollupLoop(ctx)
+	}
+	if remoteWriteURL != "" {
+		go pushLoop(ctx)
+	}
+	if graphiteAddr != "" {
+		go graphiteLoop(ctx)
+	}
+	if len(aggregatePeers) > 0 {
+		go pollPeersLoop(ctx)
+	}
+	if evLog != nil {
+		go evLog.syncLoop(ctx)
+	}
+
+	queryMux := newRouteMux("query")
 	queryMux.HandleFunc("/history", historyHandler)
+	queryMux.HandleFunc("/summary", summaryHandler)
+	queryMux.HandleFunc("/containers", containersHandler)
+	queryMux.HandleFunc("/containers/{id}/events", containerEventsHandler)
 	queryMux.HandleFunc("/stream", streamHandler)
+	queryMux.HandleFunc("/ws", wsHandler)
 	queryMux.HandleFunc("/ping", pingHandler)
+	queryMux.HandleFunc("/healthz", healthzHandler)
+	queryMux.HandleFunc("/readyz", readyzHandler)
+	queryMux.HandleFunc("/debug/self", selfHandler)
+	queryMux.HandleFunc("/debug/collectors", collectorsHandler)
+	queryMux.HandleFunc("/version", versionHandler)
+	queryMux.HandleFunc("/grafana/{$}", grafanaHealthHandler)
+	queryMux.HandleFunc("/grafana/search", grafanaSearchHandler)
+	queryMux.HandleFunc("/grafana/query", grafanaQueryHandler)
+	queryMux.Handle("/metrics", promhttp.Handler())
+	if len(aggregatePeers) > 0 {
+		queryMux.HandleFunc("/nodes", nodesHandler)
+		queryMux.HandleFunc("/fleet/history", fleetHistoryHandler)
+	}
+	if evLog != nil {
+		queryMux.HandleFunc("/events/replay", replayHandler)
+	}
+	if pprofEnabled {
+		// Only on the query API: profiles expose internals, and the
+		// ingest API may be reachable from less trusted tracers.
+		queryMux.HandleFunc("/debug/pprof/", pprof.Index)
+		queryMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		queryMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		queryMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		queryMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 
-	ingestMux := http.NewServeMux()
+	ingestMux := newRouteMux("ingest")
 	ingestMux.HandleFunc("/events", eventIngestHandler) // Ingest OOM, Lifecycle events
+	ingestMux.HandleFunc("/events/batch", batchIngestHandler)
+
+	// Listen up front so both addresses are validated and the resolved
+	// ports (e.g. for ":0") are logged before serving.
+	ingestLn, err := listen(ingestAddr)
+	if err != nil {
+		fatal("ingest listen", err, "addr", ingestAddr)
+	}
+	queryLn, err := listen(queryAddr)
+	if err != nil {
+		fatal("query listen", err, "addr", queryAddr)
+	}
+	var grpcLn net.Listener
+	if grpcAddr != "" {
+		if grpcLn, err = listen(grpcAddr); err != nil {
+			fatal("grpc listen", err, "addr", grpcAddr)
+		}
+	}
+
+	// Request contexts derive from ctx so long-lived SSE streams end as soon
+	// as a signal arrives instead of holding Shutdown until the timeout.
+	baseCtx := func(net.Listener) context.Context { return ctx }
+	ingestSrv := &http.Server{Handler: withLogging(ingestMux.withNotFound()), BaseContext: baseCtx}
+	// CORS is for browser dashboards; the ingest API is internal.
+	querySrv := &http.Server{Handler: withLogging(withCORS(queryMux.withNotFound())), BaseContext: baseCtx}
 
+	errc := make(chan error, 3)
 	go func() {
-		log.Println("nodecollector ingest server listening on :3101")
-		log.Fatal(http.ListenAndServe(":3101", ingestMux))
+		slog.Info("ingest server listening", "addr", ingestLn.Addr().String())
+		errc <- ingestSrv.Serve(ingestLn)
 	}()
+	go func() {
+		slog.Info("query server listening", "addr", queryLn.Addr().String())
+		errc <- querySrv.Serve(queryLn)
+	}()
+	grpcSrv := newGRPCServer(ctx)
+	if grpcLn != nil {
+		go func() {
+			slog.Info("grpc server listening", "addr", grpcLn.Addr().String())
+			errc <- grpcSrv.Serve(grpcLn)
+		}()
+	}
+
+	select {
+	case err := <-errc:
+		fatal("server failed", err)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("shutting down")
 
-	log.Println("nodecollector query server listening on :3100")
-	log.Fatal(http.ListenAndServe(":3100", queryMux))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := ingestSrv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("ingest server shutdown", "err", err)
+	}
+	if err := querySrv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("query server shutdown", "err", err)
+	}
+	grpcSrv.GracefulStop()
+	<-collectDone
+	if natsConn != nil {
+		closeNATS()
+	}
+	if evLog != nil {
+		if err := evLog.close(); err != nil {
+			slog.Error("closing event log", "path", eventLogPath, "err", err)
+		}
+	}
+	if stateFile != "" {
+		if err := saveState(stateFile); err != nil {
+			slog.Error("saving state", "path", stateFile, "err", err)
+		}
+	}
 }
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeltaPerSec(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	snap := func(v uint64, secs float64) vmstatSnapshot {
+		return vmstatSnapshot{vals: map[string]uint64{"c": v}, at: t0.Add(time.Duration(secs * float64(time.Second)))}
+	}
+	tests := []struct {
+		name      string
+		prev, cur vmstatSnapshot
+		want      uint64
+		wantReset bool
+	}{
+		{"monotonic", snap(100, 0), snap(300, 2), 100, false},
+		{"late read", snap(100, 0), snap(300, 1.6), 125, false},
+		{"flat", snap(100, 0), snap(100, 1), 0, false},
+		{"reset", snap(500, 0), snap(20, 1), 0, true},
+		{"missing key", vmstatSnapshot{at: t0}, snap(20, 1), 0, false},
+		{"zero interval", snap(100, 0), snap(300, 0), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reset := deltaPerSec(tt.prev, tt.cur, "c")
+			if got != tt.want || reset != tt.wantReset {
+				t.Errorf("deltaPerSec() = %d, %v; want %d, %v", got, reset, tt.want, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestDiskTotal(t *testing.T) {
+	io := func(b uint64) DiskIO { return DiskIO{ReadB: b, WriteB: 2 * b, ReadCount: 1, WriteCount: 1} }
+	per := map[string]DiskIO{
+		"sda":       io(100),
+		"sda1":      io(60),
+		"sda2":      io(40),
+		"nvme0n1":   io(1000),
+		"nvme0n1p1": io(1000),
+		"mmcblk0":   io(5),
+		"mmcblk0p1": io(5),
+		"sdb1":      io(7), // parent absent, so counted
+		"dm-0":      io(3),
+	}
+	tests := []struct {
+		name string
+		skip bool
+		want DiskIO
+	}{
+		{"skip partitions", true, DiskIO{ReadB: 1115, WriteB: 2230, ReadCount: 5, WriteCount: 5}},
+		{"count everything", false, DiskIO{ReadB: 2220, WriteB: 4440, ReadCount: 9, WriteCount: 9}},
+	}
+	defer func(v bool) { diskSkipPartitions = v }(diskSkipPartitions)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskSkipPartitions = tt.skip
+			if got := diskTotal(per); got != tt.want {
+				t.Errorf("diskTotal() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskLatency(t *testing.T) {
+	prev := DiskIO{ReadCount: 100, WriteCount: 100, IOTimeMs: 1000, WeightedIOMs: 2000, rwTimeMs: 3000}
+	tests := []struct {
+		name   string
+		cur    DiskIO
+		dt     time.Duration
+		want   DiskIO
+		wantOK bool
+	}{
+		{
+			"busy",
+			DiskIO{ReadCount: 150, WriteCount: 150, IOTimeMs: 1500, WeightedIOMs: 3000, rwTimeMs: 3400},
+			time.Second,
+			DiskIO{ReadCount: 150, WriteCount: 150, IOTimeMs: 1500, WeightedIOMs: 3000, rwTimeMs: 3400, UtilPercent: 50, AwaitMs: 4, QueueDepth: 1},
+			true,
+		},
+		{
+			"idle",
+			prev,
+			time.Second,
+			prev,
+			true,
+		},
+		{
+			"util capped",
+			DiskIO{ReadCount: 101, WriteCount: 100, IOTimeMs: 2100, WeightedIOMs: 2000, rwTimeMs: 3000},
+			time.Second,
+			DiskIO{ReadCount: 101, WriteCount: 100, IOTimeMs: 2100, WeightedIOMs: 2000, rwTimeMs: 3000, UtilPercent: 100},
+			true,
+		},
+		{
+			"counter went backwards",
+			DiskIO{ReadCount: 150, WriteCount: 150, IOTimeMs: 10, WeightedIOMs: 3000, rwTimeMs: 3400},
+			time.Second,
+			DiskIO{ReadCount: 150, WriteCount: 150, IOTimeMs: 10, WeightedIOMs: 3000, rwTimeMs: 3400},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cur
+			ok := diskLatency(prev, &got, tt.dt)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("diskLatency() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStreamDisconnectsStalledReader(t *testing.T) {
+	defer func(d, k time.Duration) { streamWriteTimeout, sseKeepalive = d, k }(streamWriteTimeout, sseKeepalive)
+	streamWriteTimeout, sseKeepalive = 100*time.Millisecond, time.Hour
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(withLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		streamHandler(w, r)
+	})))
+	defer srv.Close()
+
+	// Subscribe, then never read, so the socket buffers fill up and the
+	// handler's writes block.
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /stream?scope=events HTTP/1.1\r\nHost: test\r\n\r\n")
+
+	var before dto.Metric
+	streamSlowDisconnects.Write(&before)
+	big := frame{event: "test", data: bytes.Repeat([]byte("x"), 64<<10)}
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case <-done:
+			var after dto.Metric
+			streamSlowDisconnects.Write(&after)
+			if got := after.GetCounter().GetValue() - before.GetCounter().GetValue(); got != 1 {
+				t.Errorf("streamSlowDisconnects grew by %v; want 1", got)
+			}
+			return
+		case <-deadline:
+			t.Fatal("stalled stream client was not disconnected")
+		case <-time.After(time.Millisecond):
+			big.id++ // frames at or below the last id sent are skipped
+			eventHub.publish(big)
+		}
+	}
+}
+
+func TestParseEventTSUnits(t *testing.T) {
+	want := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		v       float64
+		want    time.Time
+		wantErr bool
+	}{
+		{"seconds", 1.7e9, want, false},
+		{"fractional seconds", 1.7e9 + 0.5, want.Add(500 * time.Millisecond), false},
+		{"milliseconds", 1.7e12, want, false},
+		{"microseconds", 1.7e15, want, false},
+		{"nanoseconds", 1.7e18, want, false},
+		{"negative", -1, time.Time{}, true},
+		{"too large", 1e19, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEventTS(tt.v)
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("parseEventTS(%v) = %v, %v; want %v, error %v", tt.v, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		encoding string
+		want     bool
+	}{
+		{"gzip", true},
+		{"br, gzip;q=0.8", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.0", false},
+		{"identity", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/history", nil)
+		r.Header.Set("Accept-Encoding", tt.encoding)
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v; want %v", tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptsMsgpack(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"application/msgpack", true},
+		{"application/json, application/msgpack;q=0.5", true},
+		{"application/msgpack;q=0", false},
+		{"application/msgpack; q=0.0", false},
+		{"application/msgpack;q=0.000", false},
+		{"application/json", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/history", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := acceptsMsgpack(r); got != tt.want {
+			t.Errorf("acceptsMsgpack(%q) = %v; want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupDir(t *testing.T) {
+	defer func(v string) { cgroupRoot = v }(cgroupRoot)
+	cgroupRoot = "/sys/fs/cgroup"
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"kubepods.slice/cri-abc.scope", "/sys/fs/cgroup/kubepods.slice/cri-abc.scope", true},
+		{"/kubepods.slice/cri-abc.scope", "/sys/fs/cgroup/kubepods.slice/cri-abc.scope", true},
+		{"/sys/fs/cgroup/system.slice", "/sys/fs/cgroup/system.slice", true},
+		{"a/../b", "/sys/fs/cgroup/b", true},
+		{"../../../etc", "", false},
+		{"/sys/fs/cgroup/../../etc", "", false},
+		{"/sys/fs/cgroup-other", "/sys/fs/cgroup/sys/fs/cgroup-other", true},
+	}
+	for _, tt := range tests {
+		got, ok := cgroupDir(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("cgroupDir(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	defer func(fs *flag.FlagSet, args []string, l *slog.Logger) {
+		flag.CommandLine, os.Args = fs, args
+		slog.SetDefault(l)
+	}(flag.CommandLine, os.Args, slog.Default())
+	defer func(i, r, h, k time.Duration, n string) {
+		sampleInterval, longResolution, longHistory, sseKeepalive, nodeName = i, r, h, k, n
+	}(sampleInterval, longResolution, longHistory, sseKeepalive, nodeName)
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"zero interval with default long tier", []string{"-interval=0"}, "-interval must be positive"},
+		{"negative interval", []string{"-interval=-1s"}, "-interval must be positive"},
+		{"long resolution below interval", []string{"-interval=2m"}, "-long-resolution must be a multiple of -interval"},
+		{"long resolution not a multiple", []string{"-interval=40s"}, "-long-resolution must be a multiple of -interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag.CommandLine = flag.NewFlagSet("nodecollector", flag.ContinueOnError)
+			os.Args = append([]string{"nodecollector"}, tt.args...)
+			err := parseFlags()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseFlags(%q) = %v; want error containing %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+// eventsTotal counts ingested tracer events by type.
+var eventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "node_events_total",
+	Help: "Tracer events ingested, by event type; unknown types are counted as \"other\".",
+}, []string{"type"})
+
+// streamDroppedTotal counts frames not delivered to a stream subscriber
+// because its buffer was full.
+var streamDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "node_stream_dropped_frames_total",
+	Help: "Stream frames dropped for subscribers that could not keep up.",
+})
+
+// streamSlowDisconnects counts stream clients dropped for not reading a
+// frame within -stream-write-timeout.
+var streamSlowDisconnects = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "node_stream_slow_disconnects_total",
+	Help: "Stream clients disconnected for failing to absorb a frame in time.",
+})
+
+// collectionOverruns counts sample slots skipped because collection took
+// longer than the interval.
+var collectionOverruns = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "node_collection_overruns_total",
+	Help: "Sample intervals skipped because node collection overran.",
+})
+
+// natsDroppedTotal counts events that could not be published to NATS
+// because the reconnect buffer was full.
+var natsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "node_nats_dropped_events_total",
+	Help: "Events not published to NATS because the outage buffer was full.",
+})
+
+// graphiteSendErrors counts stats samples that could not be sent to
+// -graphite.
+var graphiteSendErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "node_graphite_send_errors_total",
+	Help: "Stats samples not sent to Graphite because the connection or write failed.",
+})
+
+var perCPUDesc = prometheus.NewDesc("node_cpu_percent_per_cpu",
+	"CPU utilization percent per logical CPU.", []string{"cpu"}, nil)
+
+// nodeCollector exports the latest NodeVmstat sample as gauges. Metric names
+// are the snapshot's JSON keys prefixed with "node_".
+type nodeCollector struct {
+	descs []*prometheus.Desc // parallel to statFields
+}
+
+func newNodeCollector() *nodeCollector {
+	c := &nodeCollector{}
+	for _, f := range statFields {
+		c.descs = append(c.descs, prometheus.NewDesc("node_"+f.name,
+			"Latest sampled value of the "+f.name+" node stat.", nil, nil))
+	}
+	return c
+}
+
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs {
+		ch <- d
+	}
+	ch <- perCPUDesc
+}
+
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	s, ok := nodeHist.latest()
+	if !ok {
+		return
+	}
+	for i, f := range statFields {
+		ch <- prometheus.MustNewConstMetric(c.descs[i], prometheus.GaugeValue, f.value(&s))
+	}
+	for i, pct := range s.PerCPUPercent {
+		ch <- prometheus.MustNewConstMetric(perCPUDesc, prometheus.GaugeValue, pct, strconv.Itoa(i))
+	}
+}
+
+func init() {
+	prometheus.MustRegister(eventsTotal, streamDroppedTotal, streamSlowDisconnects, collectionOverruns, natsDroppedTotal, graphiteSendErrors, newNodeCollector())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// statusRecorder captures the status code written through it. It passes
+// Flush and Hijack through so SSE and WebSocket handlers keep working, and
+// Unwrap lets http.ResponseController reach the underlying writer.
+// FlushError is what http.ResponseController.Flush calls, so a failed or
+// timed-out flush reaches the caller instead of being swallowed by Flush.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Flush() { w.FlushError() }
+
+func (w *statusRecorder) FlushError() error {
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// withLogging logs each request's method, path, status and duration, and
+// turns a handler panic into a 500 instead of a dropped connection.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p) // deliberate abort; let net/http handle it
+				}
+				slog.Error("handler panicked", "method", r.Method, "path", r.URL.Path,
+					"panic", p, "stack", string(debug.Stack()))
+				if rec.status == 0 {
+					writeError(rec, r, 500, "internal error")
+				}
+			}
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			slog.Info("request", "method", r.Method, "path", r.URL.Path,
+				"status", status, "duration", time.Since(start).String())
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// originAllowed reports whether -allow-origin admits origin.
+func originAllowed(origin string) bool {
+	return allowOrigins["*"] || allowOrigins[origin]
+}
+
+// withCORS lets browser pages on the -allow-origin origins read the query
+// API, including /stream through EventSource, and answers preflights.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !originAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		if allowOrigins["*"] {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Last-Event-ID")
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeMux is an http.ServeMux that remembers its patterns, so a request
+// for an unknown path can be told which routes this server does have.
+type routeMux struct {
+	*http.ServeMux
+	server string // "query" or "ingest", reported in 404s
+	routes []string
+}
+
+func newRouteMux(server string) *routeMux {
+	return &routeMux{ServeMux: http.NewServeMux(), server: server}
+}
+
+func (m *routeMux) Handle(pattern string, h http.Handler) {
+	m.ServeMux.Handle(pattern, h)
+	m.routes = append(m.routes, pattern)
+}
+
+func (m *routeMux) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(h))
+}
+
+// notFoundError is the JSON body of a 404 from a routeMux.
+type notFoundError struct {
+	apiError
+	Server string   `json:"server"`
+	Routes []string `json:"routes"`
+}
+
+// withNotFound registers a catch-all that answers unmatched paths with a
+// JSON 404 listing the routes registered so far. Call it last.
+func (m *routeMux) withNotFound() *routeMux {
+	routes := slices.Sorted(slices.Values(m.routes))
+	m.ServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONStatus(w, r, http.StatusNotFound, notFoundError{
+			apiError: apiError{Error: fmt.Sprintf("no route for %s on the %s server", r.URL.Path, m.server), Code: http.StatusNotFound},
+			Server:   m.server,
+			Routes:   routes,
+		})
+	})
+	return m
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"log/slog"
+	"time"
+)
+
+var (
+	natsURL       string // NATS server events are published to; empty disables publishing
+	natsSubject   = "nodecollector.events"
+	natsBufferLen = 8 << 20 // bytes of events buffered while disconnected
+	natsConn      *nats.Conn
+)
+
+// natsFlushTimeout bounds how long shutdown waits for buffered events to
+// reach NATS.
+const natsFlushTimeout = 5 * time.Second
+
+// connectNATS connects to natsURL. It succeeds even if the server is down:
+// the client keeps reconnecting forever and buffers up to natsBufferLen
+// bytes of events meanwhile, so a transient outage loses nothing.
+func connectNATS() (*nats.Conn, error) {
+	return nats.Connect(natsURL,
+		nats.Name("nodecollector "+nodeName),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectBufSize(natsBufferLen),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("nats disconnected; buffering events", "url", natsURL, "err", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			slog.Info("nats reconnected", "url", nc.ConnectedUrl())
+		}),
+	)
+}
+
+// publishEvent sends ev to natsSubject as JSON. A publish only fails once
+// the reconnect buffer is full; those events are counted in
+// natsDroppedTotal rather than silently lost.
+func publishEvent(ev Event) {
+	b, err := json.Marshal(ev)
+	if err == nil {
+		err = natsConn.Publish(natsSubject, b)
+	}
+	if err != nil {
+		natsDroppedTotal.Inc()
+		slog.Error("publishing event to nats", "subject", natsSubject, "err", err)
+	}
+}
+
+// closeNATS flushes buffered events and closes the connection. Call it once
+// ingestion has stopped.
+func closeNATS() {
+	if natsConn.IsConnected() {
+		if err := natsConn.FlushTimeout(natsFlushTimeout); err != nil {
+			slog.Error("flushing nats", "url", natsURL, "err", err)
+		}
+	}
+	natsConn.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedState is the on-disk form of the history rings.
+type persistedState struct {
+	Stats  []NodeVmstat `json:"stats"`
+	Events []Event      `json:"events"`
+}
+
+// saveState atomically writes both rings to path: the snapshot goes to a
+// temp file in the same directory which is then renamed over path, so a
+// crash mid-write leaves the previous snapshot intact.
+func saveState(path string) error {
+	st := persistedState{Stats: nodeHist.snapshot(), Events: ctrEvts.snapshot()}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after a successful rename
+	if err := json.NewEncoder(f).Encode(st); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// loadState refills the rings from path, skipping entries older than the
+// history window. A missing file is not an error.
+func loadState(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st persistedState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return err
+	}
+	cutoff := timeRange{from: time.Now().Add(-historyWindow)}
+	stats := filterRange(st.Stats, cutoff, statTime)
+	for _, s := range stats {
+		nodeHist.append(s)
+	}
+	evts := filterRange(st.Events, cutoff, eventTime)
+	for _, ev := range evts {
+		// ts was saved as a string; restore the normalized form.
+		ev["ts"], _ = eventTime(ev)
+		ctrEvts.append(ev)
+		trackContainer(ev)
+	}
+	slog.Info("restored state", "stats", len(stats), "events", len(evts), "path", path)
+	return nil
+}
+
+// persistLoop saves the rings to path every interval until ctx is done.
+func persistLoop(ctx context.Context, path string, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := saveState(path); err != nil {
+				slog.Error("saving state", "path", path, "err", err)
+			}
+		}
+	}
+}
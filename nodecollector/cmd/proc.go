@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// psiAvgs are the avg10/avg60/avg300 stall percentages of one PSI line.
+type psiAvgs struct{ Avg10, Avg60, Avg300 float64 }
+
+// psi is the pressure stall information for one resource.
+type psi struct{ Some, Full psiAvgs }
+
+// readPressure parses /proc/pressure/<resource> (cpu, memory or io). The
+// files only exist on kernels built with PSI, so callers should treat an
+// error as "not available".
+func readPressure(resource string) (psi, error) {
+	f, err := os.Open("/proc/pressure/" + resource)
+	if err != nil {
+		return psi{}, err
+	}
+	defer f.Close()
+	var p psi
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 {
+			continue
+		}
+		var a *psiAvgs
+		switch fs[0] {
+		case "some":
+			a = &p.Some
+		case "full":
+			a = &p.Full
+		default:
+			continue
+		}
+		for _, kv := range fs[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "avg10":
+				a.Avg10 = n
+			case "avg60":
+				a.Avg60 = n
+			case "avg300":
+				a.Avg300 = n
+			}
+		}
+	}
+	return p, sc.Err()
+}
+
+// readFileNr parses /proc/sys/fs/file-nr into the number of allocated file
+// handles and the system-wide maximum.
+func readFileNr() (open, max uint64, err error) {
+	b, err := os.ReadFile("/proc/sys/fs/file-nr")
+	if err != nil {
+		return 0, 0, err
+	}
+	fs := strings.Fields(string(b))
+	if len(fs) != 3 {
+		return 0, 0, fmt.Errorf("unexpected file-nr format %q", b)
+	}
+	if open, err = strconv.ParseUint(fs[0], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	max, err = strconv.ParseUint(fs[2], 10, 64)
+	return open, max, err
+}
+
+// readSockets returns the "sockets: used" count from /proc/net/sockstat.
+func readSockets() (uint64, error) {
+	b, err := os.ReadFile("/proc/net/sockstat")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if rest, ok := strings.CutPrefix(line, "sockets: used "); ok {
+			return strconv.ParseUint(strings.TrimSpace(rest), 10, 64)
+		}
+	}
+	return 0, errors.New("sockstat has no sockets line")
+}
+
+// readConntrack reads the connection tracking table's size and limit from
+// /proc/sys/net/netfilter. The files only exist while nf_conntrack is
+// loaded, so callers should treat fs.ErrNotExist as "not available".
+func readConntrack() (count, max uint64, err error) {
+	if count, err = readUint("/proc/sys/net/netfilter/nf_conntrack_count"); err != nil {
+		return 0, 0, err
+	}
+	max, err = readUint("/proc/sys/net/netfilter/nf_conntrack_max")
+	return count, max, err
+}
+
+// readEntropy reads the kernel random pool's available entropy and its
+// size, in bits. Since Linux 5.18 both read a constant 256, as the pool no
+// longer runs dry.
+func readEntropy() (avail, size uint64, err error) {
+	if avail, err = readUint("/proc/sys/kernel/random/entropy_avail"); err != nil {
+		return 0, 0, err
+	}
+	size, err = readUint("/proc/sys/kernel/random/poolsize")
+	return avail, size, err
+}
+
+// readTCPCounters reads the cumulative TCP counters (InSegs, OutSegs,
+// RetransSegs, ...) from /proc/net/snmp, where a header line naming them
+// is followed by a line with their values.
+func readTCPCounters() (vmstatSnapshot, error) {
+	b, err := os.ReadFile("/proc/net/snmp")
+	if err != nil {
+		return vmstatSnapshot{}, err
+	}
+	var header []string
+	for _, line := range strings.Split(string(b), "\n") {
+		fs := strings.Fields(line)
+		if len(fs) == 0 || fs[0] != "Tcp:" {
+			continue
+		}
+		if header == nil {
+			header = fs[1:]
+			continue
+		}
+		m := map[string]uint64{}
+		for i, v := range fs[1:] {
+			if i >= len(header) {
+				break
+			}
+			// Some columns are signed (MaxConn is -1); only counters matter.
+			if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+				m[header[i]] = n
+			}
+		}
+		return vmstatSnapshot{vals: m, at: time.Now()}, nil
+	}
+	return vmstatSnapshot{}, errors.New("snmp has no Tcp lines")
+}
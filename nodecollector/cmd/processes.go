@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/process"
+	"sort"
+	"time"
+)
+
+// ProcessInfo is the resource usage of one process.
+type ProcessInfo struct {
+	PID        int32   `json:"pid"`
+	Command    string  `json:"command"`
+	RSSB       uint64  `json:"rss_b"`
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+// ProcessSample is the top processes by memory and by CPU at one instant.
+type ProcessSample struct {
+	TS     time.Time     `json:"ts"`
+	TopRSS []ProcessInfo `json:"top_rss"`
+	TopCPU []ProcessInfo `json:"top_cpu"`
+}
+
+var (
+	topProcesses int // processes kept per ranking; 0 disables the collector
+	procHist     *ring[ProcessSample]
+)
+
+// collectProcessLoop samples the top processes every sampleInterval until
+// ctx is done. Walking every PID is expensive, so it runs apart from
+// collectNodeLoop and only when -top-processes is set.
+func collectProcessLoop(ctx context.Context) {
+	// Process handles are kept across samples because Percent measures CPU
+	// time since the previous call on the same handle.
+	procs := map[int32]*process.Process{}
+	t := time.NewTicker(sampleInterval)
+	defer t.Stop()
+	for {
+		if s, err := sampleProcesses(procs); !collectorErrs.record("processes", err) {
+			procHist.append(s)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func sampleProcesses(procs map[int32]*process.Process) (ProcessSample, error) {
+	now := time.Now()
+	pids, err := process.Pids()
+	if err != nil {
+		return ProcessSample{}, err
+	}
+	live := make(map[int32]bool, len(pids))
+	infos := make([]ProcessInfo, 0, len(pids))
+	for _, pid := range pids {
+		live[pid] = true
+		p, ok := procs[pid]
+		if !ok {
+			if p, err = process.NewProcess(pid); err != nil {
+				continue // exited since Pids
+			}
+			procs[pid] = p
+		}
+		mi, err := p.MemoryInfo()
+		if err != nil {
+			continue
+		}
+		cpuPct, _ := p.Percent(0)
+		name, _ := p.Name()
+		infos = append(infos, ProcessInfo{PID: pid, Command: name, RSSB: mi.RSS, CPUPercent: cpuPct})
+	}
+	for pid := range procs {
+		if !live[pid] {
+			delete(procs, pid)
+		}
+	}
+
+	s := ProcessSample{TS: now}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].RSSB > infos[j].RSSB })
+	s.TopRSS = append([]ProcessInfo(nil), infos[:min(topProcesses, len(infos))]...)
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CPUPercent > infos[j].CPUPercent })
+	s.TopCPU = append([]ProcessInfo(nil), infos[:min(topProcesses, len(infos))]...)
+	return s, nil
+}
+
+func processTime(s ProcessSample) (time.Time, bool) { return s.TS, true }
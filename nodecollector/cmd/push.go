@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	pushTimeout    = 10 * time.Second
+	pushMaxBackoff = 5 * time.Minute
+)
+
+var (
+	remoteWriteURL      string // endpoint the metrics are pushed to; empty disables pushing
+	remoteWriteInterval = 15 * time.Second
+)
+
+// pushStatus is the outcome of the latest metrics pushes, reported on
+// /debug/self.
+type pushStatus struct {
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_ts,omitempty"`
+}
+
+var (
+	pushMu   sync.Mutex
+	lastPush pushStatus
+)
+
+func currentPushStatus() *pushStatus {
+	if remoteWriteURL == "" {
+		return nil
+	}
+	pushMu.Lock()
+	defer pushMu.Unlock()
+	st := lastPush
+	return &st
+}
+
+// pushLoop POSTs the /metrics exposition, in OpenMetrics text format, to
+// remoteWriteURL every remoteWriteInterval until ctx is done, for hosts that
+// can't be scraped inbound. Failures back off exponentially up to
+// pushMaxBackoff.
+func pushLoop(ctx context.Context) {
+	wait := remoteWriteInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		err := pushMetrics(ctx)
+		now := time.Now()
+		pushMu.Lock()
+		if err == nil {
+			lastPush.LastSuccess = &now
+		} else {
+			lastPush.LastError = err.Error()
+			lastPush.LastErrorTime = &now
+		}
+		pushMu.Unlock()
+		if err == nil {
+			wait = remoteWriteInterval
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		wait = min(wait*2, max(pushMaxBackoff, remoteWriteInterval))
+		slog.Warn("metrics push failed", "url", remoteWriteURL, "err", err, "retry_in", wait.String())
+	}
+}
+
+func pushMetrics(ctx context.Context) error {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	format := expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	enc := expfmt.NewEncoder(&buf, format)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	if c, ok := enc.(expfmt.Closer); ok {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, remoteWriteURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(format))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push rejected: %s", resp.Status)
+	}
+	return nil
+}
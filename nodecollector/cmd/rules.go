@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// thresholdRule emits a threshold event when a stat has satisfied a
+// comparison for at least a duration, and another when it stops.
+type thresholdRule struct {
+	text  string // as given to -rule, reported in its events
+	field statField
+	op    string
+	limit float64
+	dur   time.Duration
+
+	since  time.Time // when the condition last became true; zero while false
+	firing bool
+}
+
+var (
+	rulesMu        sync.Mutex
+	thresholdRules []*thresholdRule
+)
+
+var ruleRe = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(>=|<=|>|<)\s*(\S+?)\s*(?:\s+for\s+(\S+))?\s*$`)
+
+// parseRule parses a rule such as "cpu_percent>90 for 10s". The field is a
+// numeric stats JSON key; "for" defaults to a single sample.
+func parseRule(s string) (*thresholdRule, error) {
+	m := ruleRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid rule %q: want <field><op><value> [for <duration>]", s)
+	}
+	r := &thresholdRule{text: strings.TrimSpace(s), op: m[2]}
+	var ok bool
+	for _, f := range statFields {
+		if f.name == m[1] {
+			r.field, ok = f, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid rule %q: unknown numeric field %q", s, m[1])
+	}
+	var err error
+	if r.limit, err = strconv.ParseFloat(m[3], 64); err != nil {
+		return nil, fmt.Errorf("invalid rule %q: bad value %q", s, m[3])
+	}
+	if m[4] != "" {
+		if r.dur, err = time.ParseDuration(m[4]); err != nil || r.dur < 0 {
+			return nil, fmt.Errorf("invalid rule %q: bad duration %q", s, m[4])
+		}
+	}
+	return r, nil
+}
+
+func (r *thresholdRule) holds(v float64) bool {
+	switch r.op {
+	case ">":
+		return v > r.limit
+	case ">=":
+		return v >= r.limit
+	case "<":
+		return v < r.limit
+	}
+	return v <= r.limit
+}
+
+// evaluate advances the rule with a new sample, returning the event to emit
+// when it starts or stops firing. Only those transitions emit, so a
+// sustained breach yields one event rather than one per tick.
+func (r *thresholdRule) evaluate(s *NodeVmstat) (Event, bool) {
+	v := r.field.value(s)
+	var state string
+	if r.holds(v) {
+		if r.since.IsZero() {
+			r.since = s.TS
+		}
+		if r.firing || s.TS.Sub(r.since) < r.dur {
+			return nil, false
+		}
+		r.firing, state = true, "firing"
+	} else {
+		r.since = time.Time{}
+		if !r.firing {
+			return nil, false
+		}
+		r.firing, state = false, "resolved"
+	}
+	return Event{
+		"type": "threshold", "ts": s.TS, "rule": r.text, "state": state,
+		"metric": r.field.name, "value": v, "threshold": r.limit,
+	}, true
+}
+
+// evaluateRules runs every rule against a stored sample and stores the
+// events they emit.
+func evaluateRules(s *NodeVmstat) {
+	var evs []Event
+	rulesMu.Lock()
+	for _, r := range thresholdRules {
+		if ev, ok := r.evaluate(s); ok {
+			evs = append(evs, ev)
+		}
+	}
+	rulesMu.Unlock()
+	for _, ev := range evs {
+		storeEvent(ev)
+	}
+}
+
+// replaceRules swaps in a reloaded rule set. A rule whose text is
+// unchanged keeps its state, so a reload neither re-fires nor forgets a
+// breach in progress.
+func replaceRules(rules []*thresholdRule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	old := map[string]*thresholdRule{}
+	for _, r := range thresholdRules {
+		old[r.text] = r
+	}
+	for i, r := range rules {
+		if o, ok := old[r.text]; ok {
+			rules[i] = o
+		}
+	}
+	thresholdRules = rules
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// selfStats is the collector's own resource usage.
+type selfStats struct {
+	TS              time.Time        `json:"ts"`
+	Goroutines      uint64           `json:"goroutines"`
+	HeapAllocB      uint64           `json:"heap_alloc_b"`
+	SysB            uint64           `json:"sys_b"` // all memory mapped by the Go runtime
+	GCCycles        int64            `json:"gc_cycles"`
+	GCPauseTotalSec float64          `json:"gc_pause_total_s"`
+	GCLastPauseSec  float64          `json:"gc_last_pause_s"`
+	ActiveStreams   int64            `json:"active_streams"`
+	EventBytes      int              `json:"event_bytes,omitempty"` // retained events' JSON size, with -event-history-bytes
+	Push            *pushStatus      `json:"push,omitempty"`        // set when -remote-write is on
+	Containers      *containerStatus `json:"containers,omitempty"`  // set when -container-interval is on
+	Build           buildInfo        `json:"build"`
+}
+
+// selfMetrics are read with runtime/metrics, which unlike
+// runtime.ReadMemStats does not stop the world.
+var selfMetrics = []metrics.Sample{
+	{Name: "/sched/goroutines:goroutines"},
+	{Name: "/memory/classes/heap/objects:bytes"},
+	{Name: "/memory/classes/total:bytes"},
+}
+
+var lastSelf atomic.Pointer[selfStats]
+
+// sampleSelf records the collector's resource usage. It runs once per
+// collection interval so /debug/self never does the work on demand.
+func sampleSelf() {
+	metrics.Read(selfMetrics)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	st := &selfStats{
+		TS:              time.Now(),
+		Goroutines:      selfMetrics[0].Value.Uint64(),
+		HeapAllocB:      selfMetrics[1].Value.Uint64(),
+		SysB:            selfMetrics[2].Value.Uint64(),
+		GCCycles:        gc.NumGC,
+		GCPauseTotalSec: gc.PauseTotal.Seconds(),
+	}
+	if len(gc.Pause) > 0 {
+		st.GCLastPauseSec = gc.Pause[0].Seconds()
+	}
+	lastSelf.Store(st)
+}
+
+func selfHandler(w http.ResponseWriter, r *http.Request) {
+	st := lastSelf.Load()
+	if st == nil {
+		st = &selfStats{}
+	}
+	// Streams come and go between samples, so report the live count.
+	out := *st
+	out.ActiveStreams = activeStreams.Load()
+	out.EventBytes = ctrEvts.usedBytes()
+	out.Push = currentPushStatus()
+	out.Containers = currentContainerStatus()
+	out.Build = currentBuild()
+	writeJSON(w, r, out)
+}
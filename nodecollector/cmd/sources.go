@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"math"
+	"time"
+)
+
+// The built-in node stats sources. Collectors that derive rates report
+// "counter_reset" only when a counter went backwards, so merging never
+// clears another collector's reset flag.
+func init() {
+	registerCollector(&cpuCollector{})
+	registerCollector(memCollector{})
+	registerCollector(swapCollector{})
+	registerCollector(loadCollector{})
+	registerCollector(psiCollector{})
+	registerCollector(fdsCollector{})
+	registerCollector(&diskCollector{})
+	registerCollector(&netCollector{})
+	registerCollector(conntrackCollector{})
+	registerCollector(&tcpCollector{})
+	registerCollector(entropyCollector{})
+	registerCollector(&vmstatCollector{})
+	registerCollector(&procStatCollector{})
+}
+
+const mb = 1024 * 1024
+
+// cpuCollector reports total and per-CPU utilization since its last call,
+// and how the time was split between user, system, iowait, steal and idle.
+type cpuCollector struct {
+	total float64 // carried forward when a read fails
+	prev  *cpu.TimesStat
+}
+
+func (*cpuCollector) Name() string { return "cpu" }
+
+func (c *cpuCollector) Collect(ctx context.Context) (map[string]any, error) {
+	pct, err := cpu.PercentWithContext(ctx, 0, false)
+	if err == nil && len(pct) == 0 {
+		err = errors.New("cpu.Percent returned no values")
+	}
+	if err == nil {
+		c.total = pct[0]
+	}
+	perCPU, _ := cpu.PercentWithContext(ctx, 0, true)
+	out := map[string]any{"cpu_percent": c.total, "per_cpu_percent": perCPU}
+
+	times, terr := cpu.TimesWithContext(ctx, false)
+	if terr == nil && len(times) == 0 {
+		terr = errors.New("cpu.Times returned no values")
+	}
+	if terr != nil {
+		c.prev = nil
+		return out, errors.Join(err, terr)
+	}
+	cur := times[0]
+	if c.prev != nil {
+		// Guest time is already counted in user time on Linux, so it is
+		// left out of the total.
+		busy := func(t *cpu.TimesStat) float64 {
+			return t.User + t.System + t.Idle + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+		}
+		if d := busy(&cur) - busy(c.prev); d > 0 {
+			share := func(cur, prev float64) float64 { return math.Max(cur-prev, 0) / d * 100 }
+			out["cpu_user_percent"] = share(cur.User, c.prev.User)
+			out["cpu_system_percent"] = share(cur.System, c.prev.System)
+			out["cpu_iowait_percent"] = share(cur.Iowait, c.prev.Iowait)
+			out["cpu_steal_percent"] = share(cur.Steal, c.prev.Steal)
+			out["cpu_idle_percent"] = share(cur.Idle, c.prev.Idle)
+		}
+	}
+	c.prev = &cur
+	return out, err
+}
+
+type memCollector struct{}
+
+func (memCollector) Name() string { return "mem" }
+
+func (memCollector) Collect(ctx context.Context) (map[string]any, error) {
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"mem_used_mb": vm.Used / mb, "mem_total_mb": vm.Total / mb,
+		"mem_available_mb": vm.Available / mb, "mem_cached_mb": vm.Cached / mb, "mem_buffers_mb": vm.Buffers / mb,
+		"hugepages_total": vm.HugePagesTotal, "hugepages_free": vm.HugePagesFree, "hugepages_rsvd": vm.HugePagesRsvd,
+		"anon_hugepages_mb": vm.AnonHugePages / mb,
+		"slab_mb":           vm.Slab / mb, "slab_reclaimable_mb": vm.Sreclaimable / mb, "slab_unreclaim_mb": vm.Sunreclaim / mb,
+	}, nil
+}
+
+type swapCollector struct{}
+
+func (swapCollector) Name() string { return "swap" }
+
+func (swapCollector) Collect(ctx context.Context) (map[string]any, error) {
+	sw, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"swap_used_mb": sw.Used / mb, "swap_total_mb": sw.Total / mb}, nil
+}
+
+// loadCollector reports the load averages; left zero where unsupported.
+type loadCollector struct{}
+
+func (loadCollector) Name() string { return "load" }
+
+func (loadCollector) Collect(ctx context.Context) (map[string]any, error) {
+	la, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"load1": la.Load1, "load5": la.Load5, "load15": la.Load15}, nil
+}
+
+// psiCollector reports pressure stall info. Kernels without PSI leave it
+// zero, which is not treated as a failure.
+type psiCollector struct{}
+
+func (psiCollector) Name() string { return "psi" }
+
+func (psiCollector) Collect(context.Context) (map[string]any, error) {
+	out := map[string]any{}
+	var errs []error
+	for _, res := range []struct{ file, key string }{{"cpu", "cpu"}, {"memory", "mem"}, {"io", "io"}} {
+		p, err := readPressure(res.file)
+		errs = append(errs, ignoreNotExist(err))
+		for kind, a := range map[string]psiAvgs{"some": p.Some, "full": p.Full} {
+			out[fmt.Sprintf("%s_pressure_%s10", res.key, kind)] = a.Avg10
+			out[fmt.Sprintf("%s_pressure_%s60", res.key, kind)] = a.Avg60
+			out[fmt.Sprintf("%s_pressure_%s300", res.key, kind)] = a.Avg300
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// fdsCollector reports file descriptor and socket usage; absent files leave
+// them zero.
+type fdsCollector struct{}
+
+func (fdsCollector) Name() string { return "fds" }
+
+func (fdsCollector) Collect(context.Context) (map[string]any, error) {
+	openFDs, maxFDs, fdErr := readFileNr()
+	sockets, sockErr := readSockets()
+	return map[string]any{"open_fds": openFDs, "max_fds": maxFDs, "sockets_used": sockets},
+		errors.Join(ignoreNotExist(fdErr), ignoreNotExist(sockErr))
+}
+
+// diskCollector reports cumulative block device IO and byte rates, and
+// each device's utilization, average wait and queue depth.
+type diskCollector struct {
+	rates  counterRates
+	prev   map[string]DiskIO // per-device counters at prevAt
+	prevAt time.Time
+}
+
+func (*diskCollector) Name() string { return "disk" }
+
+func (c *diskCollector) Collect(context.Context) (map[string]any, error) {
+	total, per, err := readDiskIO()
+	if err != nil {
+		c.rates, c.prev = counterRates{}, nil
+		return nil, err
+	}
+	now := time.Now()
+	out := map[string]any{"disk_read_b": total.ReadB, "disk_write_b": total.WriteB, "per_disk": per}
+	for name, d := range per {
+		if p, ok := c.prev[name]; ok {
+			if !diskLatency(p, &d, now.Sub(c.prevAt)) {
+				out["counter_reset"] = true
+			}
+			per[name] = d
+		}
+	}
+	c.prev, c.prevAt = per, now
+	cur := vmstatSnapshot{vals: map[string]uint64{"read_b": total.ReadB, "write_b": total.WriteB}, at: now}
+	c.rates.update(out, cur, map[string]string{"read_b": "disk_read_bps", "write_b": "disk_write_bps"})
+	return out, nil
+}
+
+// netCollector reports cumulative network counters and byte rates.
+type netCollector struct {
+	rates counterRates
+}
+
+func (*netCollector) Name() string { return "net" }
+
+func (c *netCollector) Collect(context.Context) (map[string]any, error) {
+	total, per, cur, err := readNetIO()
+	out := map[string]any{
+		"net_rx_bytes": total.RxBytes, "net_tx_bytes": total.TxBytes,
+		"net_rx_packets": total.RxPackets, "net_tx_packets": total.TxPackets,
+		"net_rx_errs": total.RxErrs, "net_tx_errs": total.TxErrs,
+		"net_rx_drop": total.RxDrop, "net_tx_drop": total.TxDrop,
+	}
+	if perInterface {
+		out["per_net"] = per
+	}
+	c.rates.update(out, cur, map[string]string{"rx_bytes": "net_rx_bps", "tx_bytes": "net_tx_bps"})
+	return out, err
+}
+
+// conntrackCollector reports connection tracking table usage. Hosts
+// without nf_conntrack loaded leave it zero, which is not treated as a
+// failure.
+type conntrackCollector struct{}
+
+func (conntrackCollector) Name() string { return "conntrack" }
+
+func (conntrackCollector) Collect(context.Context) (map[string]any, error) {
+	count, max, err := readConntrack()
+	return map[string]any{"conntrack_count": count, "conntrack_max": max}, ignoreNotExist(err)
+}
+
+// tcpCollector reports TCP segment and retransmit rates from
+// /proc/net/snmp.
+type tcpCollector struct {
+	rates counterRates
+}
+
+func (*tcpCollector) Name() string { return "tcp" }
+
+func (c *tcpCollector) Collect(context.Context) (map[string]any, error) {
+	cur, err := readTCPCounters()
+	if err != nil {
+		c.rates = counterRates{}
+		return nil, err
+	}
+	out := map[string]any{}
+	c.rates.update(out, cur, map[string]string{
+		"InSegs": "tcp_in_segs", "OutSegs": "tcp_out_segs", "RetransSegs": "tcp_retrans_segs",
+	})
+	return out, nil
+}
+
+// entropyCollector reports the kernel random pool's available entropy,
+// which crypto-heavy hosts can starve. Where the files are absent it stays
+// zero.
+type entropyCollector struct{}
+
+func (entropyCollector) Name() string { return "entropy" }
+
+func (entropyCollector) Collect(context.Context) (map[string]any, error) {
+	avail, size, err := readEntropy()
+	return map[string]any{"entropy_avail": avail, "entropy_pool_size": size}, ignoreNotExist(err)
+}
+
+// vmstatCollector reports paging and swapping rates, and the dirty and
+// writeback page counts, from /proc/vmstat.
+type vmstatCollector struct {
+	rates counterRates
+}
+
+func (*vmstatCollector) Name() string { return "vmstat" }
+
+func (c *vmstatCollector) Collect(context.Context) (map[string]any, error) {
+	cur, err := readProcVmstat()
+	out := map[string]any{}
+	c.rates.update(out, cur, map[string]string{
+		"pswpin": "pswpin", "pswpout": "pswpout",
+		"pgfault": "pgfault", "pgmajfault": "pgmajfault",
+		"pgpgin": "pgpgin", "pgpgout": "pgpgout",
+	})
+	pressure := out["pswpin"].(uint64) + out["pswpout"].(uint64)
+	out["swap_pressure"] = pressure
+	out["swap_level"] = swapLevel(pressure)
+	out["nr_dirty"] = cur.vals["nr_dirty"]
+	out["nr_writeback"] = cur.vals["nr_writeback"]
+	return out, err
+}
+
+// swapLevel classifies a swap_pressure rate against the configured
+// thresholds, giving one signal for "this box is thrashing".
+func swapLevel(pagesPerSec uint64) string {
+	switch {
+	case pagesPerSec >= swapThrashingRate:
+		return "thrashing"
+	case pagesPerSec >= swapElevatedRate:
+		return "elevated"
+	}
+	return "ok"
+}
+
+// procStatCollector reports context switch and interrupt rates, and the
+// running and blocked task counts, from /proc/stat, a measure of
+// scheduling pressure.
+type procStatCollector struct {
+	rates counterRates
+}
+
+func (*procStatCollector) Name() string { return "procstat" }
+
+func (c *procStatCollector) Collect(context.Context) (map[string]any, error) {
+	cur, err := readProcStat()
+	out := map[string]any{}
+	c.rates.update(out, cur, map[string]string{"ctxt": "context_switches", "intr": "interrupts"})
+	// Gauges, so reported as read rather than as rates.
+	out["procs_running"] = cur.vals["procs_running"]
+	out["procs_blocked"] = cur.vals["procs_blocked"]
+	return out, err
+}
+
+// counterRates turns successive counter snapshots into per-second rates.
+type counterRates struct {
+	prev vmstatSnapshot
+}
+
+// update stores the per-second rate of each counter in keys (counter name
+// to stat name) into out, flagging counter_reset when one went backwards,
+// and remembers cur for the next call. The first call yields zero rates.
+func (r *counterRates) update(out map[string]any, cur vmstatSnapshot, keys map[string]string) {
+	for counter, stat := range keys {
+		v, reset := deltaPerSec(r.prev, cur, counter)
+		out[stat] = v
+		if reset {
+			out["counter_reset"] = true
+		}
+	}
+	r.prev = cur
+}
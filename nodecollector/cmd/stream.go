@@ -0,0 +1,549 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// activeStreams is the number of open /stream connections and gRPC
+// subscriptions.
+var activeStreams atomic.Int64
+
+// acquireStream reserves one of the maxStreams slots, reporting false when
+// none is free. Each stream holds a goroutine and timers for its lifetime, so
+// they are bounded against clients that reconnect in a loop. Release the
+// slot with activeStreams.Add(-1).
+func acquireStream() bool {
+	if n := activeStreams.Add(1); maxStreams > 0 && n > int64(maxStreams) {
+		activeStreams.Add(-1)
+		return false
+	}
+	return true
+}
+
+// subscriberBuffer is how many frames a stream subscriber may fall behind
+// before it starts missing them.
+const subscriberBuffer = 64
+
+// streamRetryAfter is the Retry-After, in seconds, sent when /stream is at
+// its connection limit.
+const streamRetryAfter = "5"
+
+// hub fans frames out to stream subscribers as they are produced. publish
+// never blocks: a subscriber whose buffer is full misses the frame (counted
+// in streamDroppedTotal), so a slow client can't stall ingestion.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan frame]struct{}
+}
+
+// eventHub carries each stored event to the streams that want it.
+var eventHub hub
+
+func (h *hub) subscribe() chan frame {
+	ch := make(chan frame, subscriberBuffer)
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan frame]struct{})
+	}
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan frame) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *hub) publish(f frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- f:
+		default:
+			streamDroppedTotal.Inc()
+		}
+	}
+}
+
+// frame is one encoded stream item. event is the SSE event name: the event's
+// type for the events scope and "stats" for the stats scope. id is the
+// item's sequence number in its ring, which clients echo back in
+// Last-Event-ID to resume after a disconnect.
+type frame struct {
+	id    uint64
+	event string
+	data  []byte
+	stats bool // data is a NodeVmstat rather than an Event
+}
+
+func eventFrame(id uint64, ev Event) frame {
+	name, _ := ev["type"].(string)
+	if strings.ContainsAny(name, "\r\n") {
+		name = "" // would break SSE framing; fall back to the default event
+	}
+	b, _ := json.Marshal(ev)
+	return frame{id: id, event: name, data: restyleJSON(b)}
+}
+
+func statFrame(id uint64, s NodeVmstat) frame {
+	b, _ := json.Marshal(s)
+	return frame{id: id, event: "stats", data: restyleJSON(b), stats: true}
+}
+
+// latestStats is the newest sample's stats frame, encoded once as it is
+// stored so that every stream subscriber sends the same bytes rather than
+// marshaling the sample again on each tick.
+var latestStats atomic.Pointer[frame]
+
+// publishStats caches the frame for sample s, stored in nodeHist as seq.
+func publishStats(seq uint64, s NodeVmstat) {
+	f := statFrame(seq, s)
+	latestStats.Store(&f)
+}
+
+// latestStatsFrame returns the newest sample and its frame, reporting false
+// when there is none yet. The frame is encoded here only when the cache
+// doesn't hold it, as for history restored from -state-file.
+func latestStatsFrame() (NodeVmstat, frame, bool) {
+	s, seq, ok := nodeHist.latestSeq()
+	if !ok {
+		return s, frame{}, false
+	}
+	if f := latestStats.Load(); f != nil && f.id == seq {
+		return s, *f, true
+	}
+	return s, statFrame(seq, s), true
+}
+
+// writeSSE writes f as a server-sent event.
+func (f frame) writeSSE(w io.Writer) {
+	if f.id != 0 {
+		fmt.Fprintf(w, "id: %d\n", f.id)
+	}
+	if f.event != "" {
+		fmt.Fprintf(w, "event: %s\n", f.event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", f.data)
+}
+
+// withIdentity wraps f's payload in an identityEnvelope.
+func (f frame) withIdentity() frame {
+	b, _ := json.Marshal(identityEnvelope{Node: nodeName, Labels: nodeLabels, Data: json.RawMessage(f.data)})
+	f.data = b
+	return f
+}
+
+// latestFrame encodes the newest item for a stream scope, reporting false
+// when there is nothing to send. A non-empty types list restricts the events
+// scope to those event types. Both /stream and /ws push these frames.
+func latestFrame(scope string, types []string) (frame, bool) {
+	switch scope {
+	case "", "events":
+		ev, seq, ok := ctrEvts.latestSeq()
+		if !ok || len(filterTypes([]Event{ev}, types)) == 0 {
+			return frame{}, false
+		}
+		return eventFrame(seq, ev), true
+	case "stats":
+		_, f, ok := latestStatsFrame()
+		return f, ok
+	}
+	return frame{}, false
+}
+
+// resumeFrames encodes every retained item of a stream scope newer than
+// lastID, so a reconnecting client misses nothing still in the ring.
+func resumeFrames(scope string, lastID uint64) []frame {
+	switch scope {
+	case "", "events":
+		items, first := ctrEvts.since(lastID)
+		return encodeFrames(items, first, eventFrame)
+	case "stats":
+		items, first := nodeHist.since(lastID)
+		return encodeFrames(items, first, statFrame)
+	}
+	return nil
+}
+
+func encodeFrames[T any](items []T, first uint64, enc func(uint64, T) frame) []frame {
+	out := make([]frame, len(items))
+	for i, v := range items {
+		out[i] = enc(first+uint64(i), v)
+	}
+	return out
+}
+
+// backfillFrames encodes the trailing items of a stream scope selected by
+// spec, either a count ("30") or a duration ("1m"). The result is naturally
+// capped at the ring capacity.
+func backfillFrames(scope, spec string) ([]frame, error) {
+	switch scope {
+	case "", "events":
+		items, first := ctrEvts.since(0)
+		return trailing(items, first, spec, eventTime, eventFrame)
+	case "stats":
+		items, first := nodeHist.since(0)
+		return trailing(items, first, spec, statTime, statFrame)
+	}
+	return nil, nil
+}
+
+// trailing encodes the items selected by a backfill spec; first is the
+// sequence number of in[0].
+func trailing[T any](in []T, first uint64, spec string, ts func(T) (time.Time, bool), enc func(uint64, T) frame) ([]frame, error) {
+	var keep func(i int, v T) bool
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid backfill %q: count must not be negative", spec)
+		}
+		start := len(in) - min(n, len(in))
+		keep = func(i int, _ T) bool { return i >= start }
+	} else if d, err := time.ParseDuration(spec); err == nil {
+		tr := timeRange{from: time.Now().Add(-d)}
+		keep = func(_ int, v T) bool {
+			t, ok := ts(v)
+			return ok && tr.contains(t)
+		}
+	} else {
+		return nil, fmt.Errorf("invalid backfill %q: want a count or a duration", spec)
+	}
+	var out []frame
+	for i, v := range in {
+		if keep(i, v) {
+			out = append(out, enc(first+uint64(i), v))
+		}
+	}
+	return out, nil
+}
+
+// subscription delivers the live frames of a stream scope: each event as
+// eventHub publishes it, and the latest stats once per sample interval.
+// /stream and the gRPC Subscribe RPC both read from one. scope=both merges
+// stats and events; ids from the two rings would collide, so its frames
+// carry none and it supports neither backfill nor resumption.
+type subscription struct {
+	scope  string
+	events chan frame       // nil unless the scope carries events
+	ticks  <-chan time.Time // nil unless the scope carries stats
+	ticker *time.Ticker
+	sent   uint64 // newest id delivered, so pushed frames a backfill covered are skipped
+
+	// onchange, when set, makes tick skip stats whose key equals that of
+	// the last stats sent (lastKey).
+	onchange *changeFilter
+	lastKey  []byte
+}
+
+// subscribe opens a subscription to scope. Events are pushed as they are
+// stored rather than polled, so none are lost between ticks. Subscribe
+// before reading the ring for a backfill so nothing slips between the
+// backfill and the first pushed frame.
+func subscribe(scope string) *subscription {
+	s := &subscription{scope: scope}
+	switch scope {
+	case "", "events", "both":
+		s.events = eventHub.subscribe()
+	}
+	// Stats are sampled on a fixed interval, so they are still sent per
+	// tick.
+	if scope == "stats" || scope == "both" {
+		s.ticker = time.NewTicker(sampleInterval)
+		s.ticks = s.ticker.C
+	}
+	return s
+}
+
+// throttle sends stats at most every d instead of every sample interval;
+// each tick still carries the latest sample.
+func (s *subscription) throttle(d time.Duration) {
+	if s.ticker != nil && d > sampleInterval {
+		s.ticker.Reset(d)
+	}
+}
+
+func (s *subscription) close() {
+	if s.events != nil {
+		eventHub.unsubscribe(s.events)
+	}
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+}
+
+// tick returns the frame to send on a stats tick, reporting false when there
+// is no sample yet, or with onchange set, when it hasn't changed.
+func (s *subscription) tick() (frame, bool) {
+	v, f, ok := latestStatsFrame()
+	if !ok {
+		return frame{}, false
+	}
+	if s.onchange != nil {
+		k := s.onchange.keyOf(f.id, v)
+		if bytes.Equal(k, s.lastKey) {
+			return frame{}, false
+		}
+		s.lastKey = k
+	}
+	if s.scope == "both" {
+		f.id = 0
+	}
+	return f, true
+}
+
+// changeFilter is an onchange field set. key renders the compared stats of
+// a sample; subscribers with the same spec share its result through
+// changeKeys.
+type changeFilter struct {
+	spec string // "*" for every stat, else the sorted field list
+	key  func(NodeVmstat) []byte
+}
+
+// changeKeys holds each onchange field set's key for the newest sample, so
+// it is computed once per tick however many subscribers share the set.
+var changeKeys struct {
+	mu   sync.Mutex
+	seq  uint64
+	keys map[string][]byte
+}
+
+// keyOf returns c's key for sample v, stored in nodeHist as seq.
+func (c *changeFilter) keyOf(seq uint64, v NodeVmstat) []byte {
+	changeKeys.mu.Lock()
+	defer changeKeys.mu.Unlock()
+	if changeKeys.keys == nil || seq > changeKeys.seq {
+		changeKeys.seq, changeKeys.keys = seq, map[string][]byte{}
+	} else if seq < changeKeys.seq {
+		return c.key(v) // read just before a newer sample was keyed
+	}
+	k, ok := changeKeys.keys[c.spec]
+	if !ok {
+		k = c.key(v)
+		changeKeys.keys[c.spec] = k
+	}
+	return k
+}
+
+// parseOnChange parses the onchange stream param: a boolean to compare every
+// stat, or a comma-separated list of stats JSON keys to compare only those.
+// It returns the changeFilter for a subscription, nil when off. The
+// timestamp never counts as a change.
+func parseOnChange(s string) (*changeFilter, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if on, err := strconv.ParseBool(s); err == nil {
+		if !on {
+			return nil, nil
+		}
+		return &changeFilter{spec: "*", key: func(v NodeVmstat) []byte {
+			v.TS = time.Time{}
+			b, _ := json.Marshal(v)
+			return b
+		}}, nil
+	}
+	fields, err := parseFields(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid onchange: %w", err)
+	}
+	spec := strings.Join(slices.Compact(slices.Sorted(slices.Values(fields))), ",")
+	return &changeFilter{spec: spec, key: func(v NodeVmstat) []byte {
+		m := project([]NodeVmstat{v}, fields)[0]
+		delete(m, "ts")
+		b, _ := json.Marshal(m)
+		return b
+	}}, nil
+}
+
+// pushed returns the frame to send for an event from s.events, reporting
+// false when the backfill already delivered it.
+func (s *subscription) pushed(f frame) (frame, bool) {
+	if s.scope == "both" {
+		f.id = 0
+	} else if f.id <= s.sent {
+		return frame{}, false
+	}
+	return f, true
+}
+
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	if !acquireStream() {
+		w.Header().Set("Retry-After", streamRetryAfter)
+		writeError(w, r, 503, "too many streams")
+		return
+	}
+	defer activeStreams.Add(-1)
+
+	q := r.URL.Query()
+	scope := q.Get("scope")
+	switch scope {
+	case "", "events", "stats", "both":
+	default:
+		// Otherwise the subscription would carry nothing and the client
+		// would sit on keepalives.
+		writeError(w, r, 400, "invalid scope")
+		return
+	}
+	env, err := envelopeParam(q)
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	onchange, err := parseOnChange(q.Get("onchange"))
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	if onchange != nil && scope != "stats" && scope != "both" {
+		writeError(w, r, 400, "onchange requires scope=stats or scope=both")
+		return
+	}
+	if scope == "both" && (q.Get("backfill") != "" || r.Header.Get("Last-Event-ID") != "") {
+		// Its frames carry no ids, so there is nothing to resume from.
+		writeError(w, r, 400, "scope=both does not support backfill or Last-Event-ID")
+		return
+	}
+	var minInterval time.Duration
+	if s := q.Get("min_interval"); s != "" {
+		if minInterval, err = time.ParseDuration(s); err != nil || minInterval < 0 {
+			writeError(w, r, 400, "invalid min_interval")
+			return
+		}
+	}
+	// out returns the writer for f, wrapped with the node identity when the
+	// client asked for an envelope.
+	out := func(f frame) func(io.Writer) {
+		if env {
+			f = f.withIdentity()
+		}
+		return f.writeSSE
+	}
+	sub := subscribe(scope)
+	defer sub.close()
+	sub.onchange = onchange
+	sub.throttle(minInterval)
+	var backfill []frame
+	if lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		// A reconnect: replay what was missed instead of the backfill,
+		// which the client already received on its first connect.
+		backfill = resumeFrames(scope, lastID)
+	} else if spec := q.Get("backfill"); spec != "" {
+		if backfill, err = backfillFrames(scope, spec); err != nil {
+			writeError(w, r, 400, err.Error())
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, r, 500, "stream unsupported")
+		return
+	}
+
+	// send writes and flushes under a deadline, so a client that stops
+	// reading is disconnected instead of blocking this goroutine forever.
+	rc := http.NewResponseController(w)
+	send := func(write func(io.Writer)) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		write(w)
+		if err := rc.Flush(); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				slog.Warn("disconnecting slow stream client", "remote", r.RemoteAddr)
+				streamSlowDisconnects.Inc()
+			}
+			return false
+		}
+		return true
+	}
+
+	// Give the client history context (or its missed frames) before the
+	// first live frame.
+	if !send(func(w io.Writer) {
+		for _, f := range backfill {
+			out(f)(w)
+			sub.sent = f.id
+		}
+	}) {
+		return
+	}
+
+	// Keep idle streams alive through proxies and NAT that drop quiet
+	// connections; wrote tracks whether anything went out since the last
+	// keepalive tick.
+	ka := time.NewTicker(sseKeepalive)
+	defer ka.Stop()
+	wrote := len(backfill) > 0
+	// With min_interval, events are held and flushed together once per
+	// interval. At most eventHistory are held, as many as the ring keeps;
+	// past that the oldest is dropped, as the hub drops frames for a
+	// subscriber that falls behind.
+	var pending []frame
+	var flush <-chan time.Time
+	if minInterval > 0 && sub.events != nil {
+		t := time.NewTicker(minInterval)
+		defer t.Stop()
+		flush = t.C
+	}
+	for {
+		select {
+		case <-sub.ticks:
+			if f, ok := sub.tick(); ok {
+				if !send(out(f)) {
+					return
+				}
+				wrote = true
+			}
+		case f := <-sub.events:
+			f, ok := sub.pushed(f)
+			if !ok {
+				continue
+			}
+			if flush != nil {
+				if len(pending) >= max(eventHistory, 1) {
+					pending = pending[1:]
+					streamDroppedTotal.Inc()
+				}
+				pending = append(pending, f)
+				continue
+			}
+			if !send(out(f)) {
+				return
+			}
+			wrote = true
+		case <-flush:
+			if len(pending) == 0 {
+				continue
+			}
+			if !send(func(w io.Writer) {
+				for _, f := range pending {
+					out(f)(w)
+				}
+			}) {
+				return
+			}
+			pending, wrote = nil, true
+		case <-ka.C:
+			if !wrote && !send(func(w io.Writer) { fmt.Fprint(w, ": keepalive\n\n") }) {
+				return
+			}
+			wrote = false
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// fieldSummary is the rollup of one numeric stat over a window.
+type fieldSummary struct {
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Avg         float64            `json:"avg"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"` // keyed "p95" etc.
+}
+
+// statsSummary rolls every numeric stat up over the samples in a window.
+type statsSummary struct {
+	From   *time.Time              `json:"from,omitempty"` // first and last sample summarized
+	To     *time.Time              `json:"to,omitempty"`
+	Count  int                     `json:"count"`
+	Fields map[string]fieldSummary `json:"fields"`
+}
+
+// summarize rolls stats up, adding each requested percentile (0 < p <= 100).
+func summarize(stats []NodeVmstat, percentiles []float64) statsSummary {
+	out := statsSummary{Count: len(stats), Fields: map[string]fieldSummary{}}
+	if len(stats) == 0 {
+		return out
+	}
+	out.From, out.To = &stats[0].TS, &stats[len(stats)-1].TS
+	for _, f := range statFields {
+		vals := make([]float64, len(stats))
+		var sum float64
+		for i := range stats {
+			vals[i] = f.value(&stats[i])
+			sum += vals[i]
+		}
+		slices.Sort(vals)
+		s := fieldSummary{Min: vals[0], Max: vals[len(vals)-1], Avg: sum / float64(len(vals))}
+		if len(percentiles) > 0 {
+			s.Percentiles = map[string]float64{}
+			for _, p := range percentiles {
+				s.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = nearestRank(vals, p)
+			}
+		}
+		out.Fields[f.name] = s
+	}
+	return out
+}
+
+// nearestRank returns the p-th percentile of sorted by the nearest-rank
+// method: the smallest value with at least p% of the values at or below it.
+// It never interpolates, so the result is always an observed sample.
+func nearestRank(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// parsePercentiles parses a comma-separated list of percentiles in (0, 100].
+func parsePercentiles(s string) ([]float64, error) {
+	var out []float64
+	for _, f := range splitList(s) {
+		p, err := strconv.ParseFloat(f, 64)
+		if err != nil || math.IsNaN(p) || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q: want a number in (0, 100]", f)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// summaryHandler serves min, max and average of each numeric stat over the
+// retained history, or its trailing window, so clients needn't download
+// every sample to reduce them.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if scope := q.Get("scope"); scope != "" && scope != "stats" {
+		writeError(w, r, 400, "invalid scope: only stats can be summarized")
+		return
+	}
+	stats := nodeHist.snapshot()
+	if s := q.Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			writeError(w, r, 400, "invalid window")
+			return
+		}
+		stats = filterRange(stats, timeRange{from: time.Now().Add(-d)}, statTime)
+	}
+	percentiles, err := parsePercentiles(q.Get("percentiles"))
+	if err != nil {
+		writeError(w, r, 400, err.Error())
+		return
+	}
+	writeJSON(w, r, summarize(stats, percentiles))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/sensors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SensorTemp is one temperature sensor's reading.
+type SensorTemp struct {
+	Key       string  `json:"key"`
+	TempC     float64 `json:"temp_c"`
+	HighC     float64 `json:"high_c,omitempty"`     // 0 when the sensor reports no threshold
+	CriticalC float64 `json:"critical_c,omitempty"` // 0 when the sensor reports no threshold
+}
+
+// CPUThrottle is how often one CPU has been thermally throttled since boot.
+type CPUThrottle struct {
+	CPU                  int    `json:"cpu"`
+	CoreThrottleCount    uint64 `json:"core_throttle_count"`
+	PackageThrottleCount uint64 `json:"package_throttle_count"`
+}
+
+// ThermalSample is every sensor's temperature and CPU's throttle counts at
+// one instant. Either list is empty where the hardware doesn't expose it,
+// as in most VMs.
+type ThermalSample struct {
+	TS       time.Time     `json:"ts"`
+	Sensors  []SensorTemp  `json:"sensors"`
+	Throttle []CPUThrottle `json:"throttle"`
+}
+
+var (
+	thermalInterval time.Duration // how often sensors are read; 0 disables the collector
+	thermalHist     *ring[ThermalSample]
+)
+
+// collectThermalLoop samples temperatures and throttle counts every
+// thermalInterval until ctx is done. Sensor reads can be slow, so it runs
+// on its own cadence like collectFSLoop, and each read is bounded by the
+// interval.
+func collectThermalLoop(ctx context.Context) {
+	t := time.NewTicker(thermalInterval)
+	defer t.Stop()
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, thermalInterval)
+		s, err := sampleThermal(readCtx)
+		cancel()
+		if !collectorErrs.record("thermal", err) {
+			thermalHist.append(s)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func sampleThermal(ctx context.Context) (ThermalSample, error) {
+	s := ThermalSample{TS: time.Now(), Sensors: []SensorTemp{}, Throttle: []CPUThrottle{}}
+	temps, err := sensors.TemperaturesWithContext(ctx)
+	if len(temps) > 0 {
+		err = nil // flaky sensors come back as warnings beside the good ones
+	}
+	if err != nil {
+		return s, err
+	}
+	for _, t := range temps {
+		s.Sensors = append(s.Sensors, SensorTemp{Key: t.SensorKey, TempC: t.Temperature, HighC: t.High, CriticalC: t.Critical})
+	}
+	s.Throttle = readThrottleCounts()
+	return s, nil
+}
+
+// readThrottleCounts reads each CPU's thermal_throttle counters, skipping
+// CPUs without them (non-Intel, or the driver isn't loaded).
+func readThrottleCounts() []CPUThrottle {
+	out := []CPUThrottle{}
+	dirs, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/thermal_throttle")
+	for _, dir := range dirs {
+		n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(dir)), "cpu"))
+		if err != nil {
+			continue
+		}
+		core, err := readUint(filepath.Join(dir, "core_throttle_count"))
+		if err != nil {
+			continue
+		}
+		pkg, _ := readUint(filepath.Join(dir, "package_throttle_count"))
+		out = append(out, CPUThrottle{CPU: n, CoreThrottleCount: core, PackageThrottleCount: pkg})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CPU < out[j].CPU }) // the glob puts cpu10 before cpu2
+	return out
+}
+
+func thermalTime(s ThermalSample) (time.Time, bool) { return s.TS, true }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	longResolution = time.Minute    // bucket size of the long-term stats tier
+	longHistory    = 24 * time.Hour // how long the tier is kept; 0 disables it
+	longHist       *ring[NodeVmstat]
+)
+
+// rollupLoop downsamples each completed longResolution bucket of nodeHist
+// into longHist, averaging gauges and keeping counters' last values, until
+// ctx is done. It runs apart from collectNodeLoop so a rollup never delays
+// a sample.
+func rollupLoop(ctx context.Context) {
+	start := time.Now().Truncate(longResolution)
+	for {
+		end := start.Add(longResolution)
+		// Wait one sample interval past the boundary so the bucket's last
+		// sample has been stored.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(end.Add(sampleInterval))):
+		}
+		bucket := filterRange(nodeHist.snapshot(), timeRange{from: start, to: end.Add(-1)}, statTime)
+		for _, s := range downsample(bucket, longResolution, "") {
+			longHist.append(s)
+		}
+		start = end
+	}
+}
+
+// downsampledStats returns the stats within tr at resolution, reduced with
+// agg, from longHist when the query reaches back past nodeHist and nodeHist
+// otherwise.
+func downsampledStats(tr timeRange, resolution time.Duration, agg string) []NodeVmstat {
+	raw := nodeHist.snapshot()
+	if !useLongHist(tr, raw, resolution, agg) {
+		return downsample(filterRange(raw, tr, statTime), resolution, agg)
+	}
+	// The long-term tier reaches back beyond -history and is already at
+	// longResolution, but only holds completed buckets: the newest come
+	// from nodeHist so the result is as fresh as a raw query.
+	stats := filterRange(longHist.snapshot(), tr, statTime)
+	tail := tr
+	if len(stats) > 0 {
+		if next := stats[len(stats)-1].TS.Add(longResolution); next.After(tail.from) {
+			tail.from = next
+		}
+	}
+	stats = append(stats, downsample(filterRange(raw, tail, statTime), longResolution, "")...)
+	if resolution > longResolution {
+		stats = downsample(stats, resolution, agg)
+	}
+	return stats
+}
+
+// useLongHist reports whether a downsampled stats query should be answered
+// from longHist: the range starts before raw's oldest sample, its
+// resolution is a multiple of the tier's, and it uses the default
+// aggregation the tier was built with.
+func useLongHist(tr timeRange, raw []NodeVmstat, resolution time.Duration, agg string) bool {
+	if longHist == nil || resolution%longResolution != 0 || agg != "" {
+		return false
+	}
+	return len(raw) == 0 || tr.from.Before(raw[0].TS)
+}
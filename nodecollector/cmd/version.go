@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Whatever is left unset is filled from the VCS stamp Go embeds in the
+// binary, where there is one.
+var (
+	version   = "dev"
+	commit    string
+	buildDate string
+)
+
+// buildInfo identifies the running build, so a fleet rollout can be
+// verified node by node.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+var currentBuild = sync.OnceValue(func() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	if b.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		b.Version = bi.Main.Version // set by go install module@version
+	}
+	var dirty bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value
+			}
+		case "vcs.time":
+			if b.BuildDate == "" {
+				b.BuildDate = s.Value // the commit's time, the closest stamp Go records
+			}
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty && commit == "" && b.Commit != "" {
+		b.Commit += "-dirty"
+	}
+	return b
+})
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, currentBuild())
+}
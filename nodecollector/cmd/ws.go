@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: checkWSOrigin}
+
+// checkWSOrigin admits same-origin WebSocket handshakes, as the upgrader
+// does by default, plus the -allow-origin origins.
+func checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || originAllowed(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsControl is a client message that changes the subscription mid-stream.
+// Omitted fields are left unchanged.
+type wsControl struct {
+	Scope *string `json:"scope"`
+	Type  *string `json:"type"` // comma-separated event types; "" clears the filter
+}
+
+func validStreamScope(scope string) bool {
+	switch scope {
+	case "", "events", "stats":
+		return true
+	}
+	return false
+}
+
+// wsHandler pushes the same per-tick payloads as streamHandler over a
+// WebSocket. Clients may send wsControl messages to switch scope or filter.
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	scope := q.Get("scope")
+	if !validStreamScope(scope) {
+		writeError(w, r, 400, "invalid scope")
+		return
+	}
+	types := splitList(q.Get("type"))
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already replied
+	}
+	defer conn.Close()
+
+	// Only this goroutine reads; the loop below is the only writer.
+	ctrl := make(chan wsControl)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var c wsControl
+			if json.Unmarshal(msg, &c) != nil {
+				continue // ignore malformed control messages
+			}
+			select {
+			case ctrl <- c:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	t := time.NewTicker(sampleInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if f, ok := latestFrame(scope, types); ok {
+				if err := conn.WriteMessage(websocket.TextMessage, f.data); err != nil {
+					return
+				}
+			}
+		case c := <-ctrl:
+			if c.Scope != nil {
+				if !validStreamScope(*c.Scope) {
+					conn.WriteJSON(map[string]string{"error": "invalid scope"})
+					continue
+				}
+				scope = *c.Scope
+			}
+			if c.Type != nil {
+				types = splitList(*c.Type)
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+			return
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package nodecollectorpb holds the generated types and service for the
+// nodecollector gRPC streaming API.
+package nodecollectorpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative nodecollector.proto
@@ -0,0 +1,1395 @@
+// The nodecollector streaming API, an optional typed alternative to the
+// /stream SSE endpoint. Field names match the JSON keys served over HTTP.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: nodecollector.proto
+
+package nodecollectorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "events" (the default), "stats" or "both".
+	Scope string `protobuf:"bytes,1,opt,name=scope,proto3" json:"scope,omitempty"`
+	// Optionally replay recent history first, as a count ("30") or a
+	// duration ("1m"). Not supported for scope "both".
+	Backfill      string `protobuf:"bytes,2,opt,name=backfill,proto3" json:"backfill,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_nodecollector_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetScope() string {
+	if x != nil {
+		return x.Scope
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetBackfill() string {
+	if x != nil {
+		return x.Backfill
+	}
+	return ""
+}
+
+type Frame struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sequence number within the scope, as the SSE id; 0 for scope "both".
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Frame_Stats
+	//	*Frame_Event
+	Payload       isFrame_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_nodecollector_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Frame) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Frame) GetPayload() isFrame_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Frame) GetStats() *NodeVmstat {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Stats); ok {
+			return x.Stats
+		}
+	}
+	return nil
+}
+
+func (x *Frame) GetEvent() *Event {
+	if x != nil {
+		if x, ok := x.Payload.(*Frame_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+type isFrame_Payload interface {
+	isFrame_Payload()
+}
+
+type Frame_Stats struct {
+	Stats *NodeVmstat `protobuf:"bytes,2,opt,name=stats,proto3,oneof"`
+}
+
+type Frame_Event struct {
+	Event *Event `protobuf:"bytes,3,opt,name=event,proto3,oneof"`
+}
+
+func (*Frame_Stats) isFrame_Payload() {}
+
+func (*Frame_Event) isFrame_Payload() {}
+
+// Event is an ingested tracer event. Events are free-form, so the whole
+// object, including its node_stats enrichment, is carried in fields.
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Ts            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=ts,proto3" json:"ts,omitempty"`
+	Fields        *structpb.Struct       `protobuf:"bytes,3,opt,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_nodecollector_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTs() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ts
+	}
+	return nil
+}
+
+func (x *Event) GetFields() *structpb.Struct {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type DiskIO struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReadB         uint64                 `protobuf:"varint,1,opt,name=read_b,json=readB,proto3" json:"read_b,omitempty"`
+	WriteB        uint64                 `protobuf:"varint,2,opt,name=write_b,json=writeB,proto3" json:"write_b,omitempty"`
+	ReadCount     uint64                 `protobuf:"varint,3,opt,name=read_count,json=readCount,proto3" json:"read_count,omitempty"`
+	WriteCount    uint64                 `protobuf:"varint,4,opt,name=write_count,json=writeCount,proto3" json:"write_count,omitempty"`
+	IoTimeMs      uint64                 `protobuf:"varint,5,opt,name=io_time_ms,json=ioTimeMs,proto3" json:"io_time_ms,omitempty"`
+	WeightedIoMs  uint64                 `protobuf:"varint,6,opt,name=weighted_io_ms,json=weightedIoMs,proto3" json:"weighted_io_ms,omitempty"`
+	InFlight      uint64                 `protobuf:"varint,7,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	UtilPercent   float64                `protobuf:"fixed64,8,opt,name=util_percent,json=utilPercent,proto3" json:"util_percent,omitempty"`
+	AwaitMs       float64                `protobuf:"fixed64,9,opt,name=await_ms,json=awaitMs,proto3" json:"await_ms,omitempty"`
+	QueueDepth    float64                `protobuf:"fixed64,10,opt,name=queue_depth,json=queueDepth,proto3" json:"queue_depth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiskIO) Reset() {
+	*x = DiskIO{}
+	mi := &file_nodecollector_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiskIO) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiskIO) ProtoMessage() {}
+
+func (x *DiskIO) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiskIO.ProtoReflect.Descriptor instead.
+func (*DiskIO) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DiskIO) GetReadB() uint64 {
+	if x != nil {
+		return x.ReadB
+	}
+	return 0
+}
+
+func (x *DiskIO) GetWriteB() uint64 {
+	if x != nil {
+		return x.WriteB
+	}
+	return 0
+}
+
+func (x *DiskIO) GetReadCount() uint64 {
+	if x != nil {
+		return x.ReadCount
+	}
+	return 0
+}
+
+func (x *DiskIO) GetWriteCount() uint64 {
+	if x != nil {
+		return x.WriteCount
+	}
+	return 0
+}
+
+func (x *DiskIO) GetIoTimeMs() uint64 {
+	if x != nil {
+		return x.IoTimeMs
+	}
+	return 0
+}
+
+func (x *DiskIO) GetWeightedIoMs() uint64 {
+	if x != nil {
+		return x.WeightedIoMs
+	}
+	return 0
+}
+
+func (x *DiskIO) GetInFlight() uint64 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+func (x *DiskIO) GetUtilPercent() float64 {
+	if x != nil {
+		return x.UtilPercent
+	}
+	return 0
+}
+
+func (x *DiskIO) GetAwaitMs() float64 {
+	if x != nil {
+		return x.AwaitMs
+	}
+	return 0
+}
+
+func (x *DiskIO) GetQueueDepth() float64 {
+	if x != nil {
+		return x.QueueDepth
+	}
+	return 0
+}
+
+type NetIO struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RxBytes       uint64                 `protobuf:"varint,1,opt,name=rx_bytes,json=rxBytes,proto3" json:"rx_bytes,omitempty"`
+	TxBytes       uint64                 `protobuf:"varint,2,opt,name=tx_bytes,json=txBytes,proto3" json:"tx_bytes,omitempty"`
+	RxPackets     uint64                 `protobuf:"varint,3,opt,name=rx_packets,json=rxPackets,proto3" json:"rx_packets,omitempty"`
+	TxPackets     uint64                 `protobuf:"varint,4,opt,name=tx_packets,json=txPackets,proto3" json:"tx_packets,omitempty"`
+	RxErrs        uint64                 `protobuf:"varint,5,opt,name=rx_errs,json=rxErrs,proto3" json:"rx_errs,omitempty"`
+	TxErrs        uint64                 `protobuf:"varint,6,opt,name=tx_errs,json=txErrs,proto3" json:"tx_errs,omitempty"`
+	RxDrop        uint64                 `protobuf:"varint,7,opt,name=rx_drop,json=rxDrop,proto3" json:"rx_drop,omitempty"`
+	TxDrop        uint64                 `protobuf:"varint,8,opt,name=tx_drop,json=txDrop,proto3" json:"tx_drop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetIO) Reset() {
+	*x = NetIO{}
+	mi := &file_nodecollector_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetIO) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetIO) ProtoMessage() {}
+
+func (x *NetIO) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetIO.ProtoReflect.Descriptor instead.
+func (*NetIO) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NetIO) GetRxBytes() uint64 {
+	if x != nil {
+		return x.RxBytes
+	}
+	return 0
+}
+
+func (x *NetIO) GetTxBytes() uint64 {
+	if x != nil {
+		return x.TxBytes
+	}
+	return 0
+}
+
+func (x *NetIO) GetRxPackets() uint64 {
+	if x != nil {
+		return x.RxPackets
+	}
+	return 0
+}
+
+func (x *NetIO) GetTxPackets() uint64 {
+	if x != nil {
+		return x.TxPackets
+	}
+	return 0
+}
+
+func (x *NetIO) GetRxErrs() uint64 {
+	if x != nil {
+		return x.RxErrs
+	}
+	return 0
+}
+
+func (x *NetIO) GetTxErrs() uint64 {
+	if x != nil {
+		return x.TxErrs
+	}
+	return 0
+}
+
+func (x *NetIO) GetRxDrop() uint64 {
+	if x != nil {
+		return x.RxDrop
+	}
+	return 0
+}
+
+func (x *NetIO) GetTxDrop() uint64 {
+	if x != nil {
+		return x.TxDrop
+	}
+	return 0
+}
+
+// NodeVmstat is one node sample. See NodeVmstat in cmd/main.go.
+type NodeVmstat struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Ts                 *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=ts,proto3" json:"ts,omitempty"`
+	CpuPercent         float64                `protobuf:"fixed64,2,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	CpuUserPercent     float64                `protobuf:"fixed64,3,opt,name=cpu_user_percent,json=cpuUserPercent,proto3" json:"cpu_user_percent,omitempty"`
+	CpuSystemPercent   float64                `protobuf:"fixed64,4,opt,name=cpu_system_percent,json=cpuSystemPercent,proto3" json:"cpu_system_percent,omitempty"`
+	CpuIowaitPercent   float64                `protobuf:"fixed64,5,opt,name=cpu_iowait_percent,json=cpuIowaitPercent,proto3" json:"cpu_iowait_percent,omitempty"`
+	CpuStealPercent    float64                `protobuf:"fixed64,6,opt,name=cpu_steal_percent,json=cpuStealPercent,proto3" json:"cpu_steal_percent,omitempty"`
+	CpuIdlePercent     float64                `protobuf:"fixed64,7,opt,name=cpu_idle_percent,json=cpuIdlePercent,proto3" json:"cpu_idle_percent,omitempty"`
+	PerCpuPercent      []float64              `protobuf:"fixed64,8,rep,packed,name=per_cpu_percent,json=perCpuPercent,proto3" json:"per_cpu_percent,omitempty"`
+	MemUsedMb          uint64                 `protobuf:"varint,9,opt,name=mem_used_mb,json=memUsedMb,proto3" json:"mem_used_mb,omitempty"`
+	MemTotalMb         uint64                 `protobuf:"varint,10,opt,name=mem_total_mb,json=memTotalMb,proto3" json:"mem_total_mb,omitempty"`
+	MemAvailableMb     uint64                 `protobuf:"varint,11,opt,name=mem_available_mb,json=memAvailableMb,proto3" json:"mem_available_mb,omitempty"`
+	MemCachedMb        uint64                 `protobuf:"varint,12,opt,name=mem_cached_mb,json=memCachedMb,proto3" json:"mem_cached_mb,omitempty"`
+	MemBuffersMb       uint64                 `protobuf:"varint,13,opt,name=mem_buffers_mb,json=memBuffersMb,proto3" json:"mem_buffers_mb,omitempty"`
+	SwapUsedMb         uint64                 `protobuf:"varint,14,opt,name=swap_used_mb,json=swapUsedMb,proto3" json:"swap_used_mb,omitempty"`
+	SwapTotalMb        uint64                 `protobuf:"varint,15,opt,name=swap_total_mb,json=swapTotalMb,proto3" json:"swap_total_mb,omitempty"`
+	Pswpin             uint64                 `protobuf:"varint,16,opt,name=pswpin,proto3" json:"pswpin,omitempty"`
+	Pswpout            uint64                 `protobuf:"varint,17,opt,name=pswpout,proto3" json:"pswpout,omitempty"`
+	Pgfault            uint64                 `protobuf:"varint,18,opt,name=pgfault,proto3" json:"pgfault,omitempty"`
+	Pgmajfault         uint64                 `protobuf:"varint,19,opt,name=pgmajfault,proto3" json:"pgmajfault,omitempty"`
+	Pgpgin             uint64                 `protobuf:"varint,20,opt,name=pgpgin,proto3" json:"pgpgin,omitempty"`
+	Pgpgout            uint64                 `protobuf:"varint,21,opt,name=pgpgout,proto3" json:"pgpgout,omitempty"`
+	SwapPressure       uint64                 `protobuf:"varint,22,opt,name=swap_pressure,json=swapPressure,proto3" json:"swap_pressure,omitempty"`
+	SwapLevel          string                 `protobuf:"bytes,23,opt,name=swap_level,json=swapLevel,proto3" json:"swap_level,omitempty"`
+	ContextSwitches    uint64                 `protobuf:"varint,24,opt,name=context_switches,json=contextSwitches,proto3" json:"context_switches,omitempty"`
+	Interrupts         uint64                 `protobuf:"varint,25,opt,name=interrupts,proto3" json:"interrupts,omitempty"`
+	DiskReadB          uint64                 `protobuf:"varint,26,opt,name=disk_read_b,json=diskReadB,proto3" json:"disk_read_b,omitempty"`
+	DiskWriteB         uint64                 `protobuf:"varint,27,opt,name=disk_write_b,json=diskWriteB,proto3" json:"disk_write_b,omitempty"`
+	DiskReadBps        uint64                 `protobuf:"varint,28,opt,name=disk_read_bps,json=diskReadBps,proto3" json:"disk_read_bps,omitempty"`
+	DiskWriteBps       uint64                 `protobuf:"varint,29,opt,name=disk_write_bps,json=diskWriteBps,proto3" json:"disk_write_bps,omitempty"`
+	Load1              float64                `protobuf:"fixed64,30,opt,name=load1,proto3" json:"load1,omitempty"`
+	Load5              float64                `protobuf:"fixed64,31,opt,name=load5,proto3" json:"load5,omitempty"`
+	Load15             float64                `protobuf:"fixed64,32,opt,name=load15,proto3" json:"load15,omitempty"`
+	NetRxBytes         uint64                 `protobuf:"varint,33,opt,name=net_rx_bytes,json=netRxBytes,proto3" json:"net_rx_bytes,omitempty"`
+	NetTxBytes         uint64                 `protobuf:"varint,34,opt,name=net_tx_bytes,json=netTxBytes,proto3" json:"net_tx_bytes,omitempty"`
+	NetRxPackets       uint64                 `protobuf:"varint,35,opt,name=net_rx_packets,json=netRxPackets,proto3" json:"net_rx_packets,omitempty"`
+	NetTxPackets       uint64                 `protobuf:"varint,36,opt,name=net_tx_packets,json=netTxPackets,proto3" json:"net_tx_packets,omitempty"`
+	NetRxErrs          uint64                 `protobuf:"varint,37,opt,name=net_rx_errs,json=netRxErrs,proto3" json:"net_rx_errs,omitempty"`
+	NetTxErrs          uint64                 `protobuf:"varint,38,opt,name=net_tx_errs,json=netTxErrs,proto3" json:"net_tx_errs,omitempty"`
+	NetRxDrop          uint64                 `protobuf:"varint,39,opt,name=net_rx_drop,json=netRxDrop,proto3" json:"net_rx_drop,omitempty"`
+	NetTxDrop          uint64                 `protobuf:"varint,40,opt,name=net_tx_drop,json=netTxDrop,proto3" json:"net_tx_drop,omitempty"`
+	NetRxBps           uint64                 `protobuf:"varint,41,opt,name=net_rx_bps,json=netRxBps,proto3" json:"net_rx_bps,omitempty"`
+	NetTxBps           uint64                 `protobuf:"varint,42,opt,name=net_tx_bps,json=netTxBps,proto3" json:"net_tx_bps,omitempty"`
+	CpuPressureSome10  float64                `protobuf:"fixed64,43,opt,name=cpu_pressure_some10,json=cpuPressureSome10,proto3" json:"cpu_pressure_some10,omitempty"`
+	CpuPressureSome60  float64                `protobuf:"fixed64,44,opt,name=cpu_pressure_some60,json=cpuPressureSome60,proto3" json:"cpu_pressure_some60,omitempty"`
+	CpuPressureSome300 float64                `protobuf:"fixed64,45,opt,name=cpu_pressure_some300,json=cpuPressureSome300,proto3" json:"cpu_pressure_some300,omitempty"`
+	CpuPressureFull10  float64                `protobuf:"fixed64,46,opt,name=cpu_pressure_full10,json=cpuPressureFull10,proto3" json:"cpu_pressure_full10,omitempty"`
+	CpuPressureFull60  float64                `protobuf:"fixed64,47,opt,name=cpu_pressure_full60,json=cpuPressureFull60,proto3" json:"cpu_pressure_full60,omitempty"`
+	CpuPressureFull300 float64                `protobuf:"fixed64,48,opt,name=cpu_pressure_full300,json=cpuPressureFull300,proto3" json:"cpu_pressure_full300,omitempty"`
+	MemPressureSome10  float64                `protobuf:"fixed64,49,opt,name=mem_pressure_some10,json=memPressureSome10,proto3" json:"mem_pressure_some10,omitempty"`
+	MemPressureSome60  float64                `protobuf:"fixed64,50,opt,name=mem_pressure_some60,json=memPressureSome60,proto3" json:"mem_pressure_some60,omitempty"`
+	MemPressureSome300 float64                `protobuf:"fixed64,51,opt,name=mem_pressure_some300,json=memPressureSome300,proto3" json:"mem_pressure_some300,omitempty"`
+	MemPressureFull10  float64                `protobuf:"fixed64,52,opt,name=mem_pressure_full10,json=memPressureFull10,proto3" json:"mem_pressure_full10,omitempty"`
+	MemPressureFull60  float64                `protobuf:"fixed64,53,opt,name=mem_pressure_full60,json=memPressureFull60,proto3" json:"mem_pressure_full60,omitempty"`
+	MemPressureFull300 float64                `protobuf:"fixed64,54,opt,name=mem_pressure_full300,json=memPressureFull300,proto3" json:"mem_pressure_full300,omitempty"`
+	IoPressureSome10   float64                `protobuf:"fixed64,55,opt,name=io_pressure_some10,json=ioPressureSome10,proto3" json:"io_pressure_some10,omitempty"`
+	IoPressureSome60   float64                `protobuf:"fixed64,56,opt,name=io_pressure_some60,json=ioPressureSome60,proto3" json:"io_pressure_some60,omitempty"`
+	IoPressureSome300  float64                `protobuf:"fixed64,57,opt,name=io_pressure_some300,json=ioPressureSome300,proto3" json:"io_pressure_some300,omitempty"`
+	IoPressureFull10   float64                `protobuf:"fixed64,58,opt,name=io_pressure_full10,json=ioPressureFull10,proto3" json:"io_pressure_full10,omitempty"`
+	IoPressureFull60   float64                `protobuf:"fixed64,59,opt,name=io_pressure_full60,json=ioPressureFull60,proto3" json:"io_pressure_full60,omitempty"`
+	IoPressureFull300  float64                `protobuf:"fixed64,60,opt,name=io_pressure_full300,json=ioPressureFull300,proto3" json:"io_pressure_full300,omitempty"`
+	OpenFds            uint64                 `protobuf:"varint,61,opt,name=open_fds,json=openFds,proto3" json:"open_fds,omitempty"`
+	MaxFds             uint64                 `protobuf:"varint,62,opt,name=max_fds,json=maxFds,proto3" json:"max_fds,omitempty"`
+	SocketsUsed        uint64                 `protobuf:"varint,63,opt,name=sockets_used,json=socketsUsed,proto3" json:"sockets_used,omitempty"`
+	PerNet             map[string]*NetIO      `protobuf:"bytes,64,rep,name=per_net,json=perNet,proto3" json:"per_net,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	PerDisk            map[string]*DiskIO     `protobuf:"bytes,65,rep,name=per_disk,json=perDisk,proto3" json:"per_disk,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CounterReset       bool                   `protobuf:"varint,66,opt,name=counter_reset,json=counterReset,proto3" json:"counter_reset,omitempty"`
+	FailedCollectors   []string               `protobuf:"bytes,67,rep,name=failed_collectors,json=failedCollectors,proto3" json:"failed_collectors,omitempty"`
+	HugepagesTotal     uint64                 `protobuf:"varint,68,opt,name=hugepages_total,json=hugepagesTotal,proto3" json:"hugepages_total,omitempty"`
+	HugepagesFree      uint64                 `protobuf:"varint,69,opt,name=hugepages_free,json=hugepagesFree,proto3" json:"hugepages_free,omitempty"`
+	HugepagesRsvd      uint64                 `protobuf:"varint,70,opt,name=hugepages_rsvd,json=hugepagesRsvd,proto3" json:"hugepages_rsvd,omitempty"`
+	AnonHugepagesMb    uint64                 `protobuf:"varint,71,opt,name=anon_hugepages_mb,json=anonHugepagesMb,proto3" json:"anon_hugepages_mb,omitempty"`
+	NrDirty            uint64                 `protobuf:"varint,72,opt,name=nr_dirty,json=nrDirty,proto3" json:"nr_dirty,omitempty"`
+	NrWriteback        uint64                 `protobuf:"varint,73,opt,name=nr_writeback,json=nrWriteback,proto3" json:"nr_writeback,omitempty"`
+	ConntrackCount     uint64                 `protobuf:"varint,74,opt,name=conntrack_count,json=conntrackCount,proto3" json:"conntrack_count,omitempty"`
+	ConntrackMax       uint64                 `protobuf:"varint,75,opt,name=conntrack_max,json=conntrackMax,proto3" json:"conntrack_max,omitempty"`
+	TcpInSegs          uint64                 `protobuf:"varint,76,opt,name=tcp_in_segs,json=tcpInSegs,proto3" json:"tcp_in_segs,omitempty"`
+	TcpOutSegs         uint64                 `protobuf:"varint,77,opt,name=tcp_out_segs,json=tcpOutSegs,proto3" json:"tcp_out_segs,omitempty"`
+	TcpRetransSegs     uint64                 `protobuf:"varint,78,opt,name=tcp_retrans_segs,json=tcpRetransSegs,proto3" json:"tcp_retrans_segs,omitempty"`
+	ProcsRunning       uint64                 `protobuf:"varint,79,opt,name=procs_running,json=procsRunning,proto3" json:"procs_running,omitempty"`
+	ProcsBlocked       uint64                 `protobuf:"varint,80,opt,name=procs_blocked,json=procsBlocked,proto3" json:"procs_blocked,omitempty"`
+	EntropyAvail       uint64                 `protobuf:"varint,81,opt,name=entropy_avail,json=entropyAvail,proto3" json:"entropy_avail,omitempty"`
+	EntropyPoolSize    uint64                 `protobuf:"varint,82,opt,name=entropy_pool_size,json=entropyPoolSize,proto3" json:"entropy_pool_size,omitempty"`
+	SlabMb             uint64                 `protobuf:"varint,83,opt,name=slab_mb,json=slabMb,proto3" json:"slab_mb,omitempty"`
+	SlabReclaimableMb  uint64                 `protobuf:"varint,84,opt,name=slab_reclaimable_mb,json=slabReclaimableMb,proto3" json:"slab_reclaimable_mb,omitempty"`
+	SlabUnreclaimMb    uint64                 `protobuf:"varint,85,opt,name=slab_unreclaim_mb,json=slabUnreclaimMb,proto3" json:"slab_unreclaim_mb,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *NodeVmstat) Reset() {
+	*x = NodeVmstat{}
+	mi := &file_nodecollector_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeVmstat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeVmstat) ProtoMessage() {}
+
+func (x *NodeVmstat) ProtoReflect() protoreflect.Message {
+	mi := &file_nodecollector_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeVmstat.ProtoReflect.Descriptor instead.
+func (*NodeVmstat) Descriptor() ([]byte, []int) {
+	return file_nodecollector_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NodeVmstat) GetTs() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ts
+	}
+	return nil
+}
+
+func (x *NodeVmstat) GetCpuPercent() float64 {
+	if x != nil {
+		return x.CpuPercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuUserPercent() float64 {
+	if x != nil {
+		return x.CpuUserPercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuSystemPercent() float64 {
+	if x != nil {
+		return x.CpuSystemPercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuIowaitPercent() float64 {
+	if x != nil {
+		return x.CpuIowaitPercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuStealPercent() float64 {
+	if x != nil {
+		return x.CpuStealPercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuIdlePercent() float64 {
+	if x != nil {
+		return x.CpuIdlePercent
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPerCpuPercent() []float64 {
+	if x != nil {
+		return x.PerCpuPercent
+	}
+	return nil
+}
+
+func (x *NodeVmstat) GetMemUsedMb() uint64 {
+	if x != nil {
+		return x.MemUsedMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemTotalMb() uint64 {
+	if x != nil {
+		return x.MemTotalMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemAvailableMb() uint64 {
+	if x != nil {
+		return x.MemAvailableMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemCachedMb() uint64 {
+	if x != nil {
+		return x.MemCachedMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemBuffersMb() uint64 {
+	if x != nil {
+		return x.MemBuffersMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSwapUsedMb() uint64 {
+	if x != nil {
+		return x.SwapUsedMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSwapTotalMb() uint64 {
+	if x != nil {
+		return x.SwapTotalMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPswpin() uint64 {
+	if x != nil {
+		return x.Pswpin
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPswpout() uint64 {
+	if x != nil {
+		return x.Pswpout
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPgfault() uint64 {
+	if x != nil {
+		return x.Pgfault
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPgmajfault() uint64 {
+	if x != nil {
+		return x.Pgmajfault
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPgpgin() uint64 {
+	if x != nil {
+		return x.Pgpgin
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPgpgout() uint64 {
+	if x != nil {
+		return x.Pgpgout
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSwapPressure() uint64 {
+	if x != nil {
+		return x.SwapPressure
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSwapLevel() string {
+	if x != nil {
+		return x.SwapLevel
+	}
+	return ""
+}
+
+func (x *NodeVmstat) GetContextSwitches() uint64 {
+	if x != nil {
+		return x.ContextSwitches
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetInterrupts() uint64 {
+	if x != nil {
+		return x.Interrupts
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetDiskReadB() uint64 {
+	if x != nil {
+		return x.DiskReadB
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetDiskWriteB() uint64 {
+	if x != nil {
+		return x.DiskWriteB
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetDiskReadBps() uint64 {
+	if x != nil {
+		return x.DiskReadBps
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetDiskWriteBps() uint64 {
+	if x != nil {
+		return x.DiskWriteBps
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetLoad1() float64 {
+	if x != nil {
+		return x.Load1
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetLoad5() float64 {
+	if x != nil {
+		return x.Load5
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetLoad15() float64 {
+	if x != nil {
+		return x.Load15
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetRxBytes() uint64 {
+	if x != nil {
+		return x.NetRxBytes
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetTxBytes() uint64 {
+	if x != nil {
+		return x.NetTxBytes
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetRxPackets() uint64 {
+	if x != nil {
+		return x.NetRxPackets
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetTxPackets() uint64 {
+	if x != nil {
+		return x.NetTxPackets
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetRxErrs() uint64 {
+	if x != nil {
+		return x.NetRxErrs
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetTxErrs() uint64 {
+	if x != nil {
+		return x.NetTxErrs
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetRxDrop() uint64 {
+	if x != nil {
+		return x.NetRxDrop
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetTxDrop() uint64 {
+	if x != nil {
+		return x.NetTxDrop
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetRxBps() uint64 {
+	if x != nil {
+		return x.NetRxBps
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNetTxBps() uint64 {
+	if x != nil {
+		return x.NetTxBps
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureSome10() float64 {
+	if x != nil {
+		return x.CpuPressureSome10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureSome60() float64 {
+	if x != nil {
+		return x.CpuPressureSome60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureSome300() float64 {
+	if x != nil {
+		return x.CpuPressureSome300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureFull10() float64 {
+	if x != nil {
+		return x.CpuPressureFull10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureFull60() float64 {
+	if x != nil {
+		return x.CpuPressureFull60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetCpuPressureFull300() float64 {
+	if x != nil {
+		return x.CpuPressureFull300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureSome10() float64 {
+	if x != nil {
+		return x.MemPressureSome10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureSome60() float64 {
+	if x != nil {
+		return x.MemPressureSome60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureSome300() float64 {
+	if x != nil {
+		return x.MemPressureSome300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureFull10() float64 {
+	if x != nil {
+		return x.MemPressureFull10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureFull60() float64 {
+	if x != nil {
+		return x.MemPressureFull60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMemPressureFull300() float64 {
+	if x != nil {
+		return x.MemPressureFull300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureSome10() float64 {
+	if x != nil {
+		return x.IoPressureSome10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureSome60() float64 {
+	if x != nil {
+		return x.IoPressureSome60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureSome300() float64 {
+	if x != nil {
+		return x.IoPressureSome300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureFull10() float64 {
+	if x != nil {
+		return x.IoPressureFull10
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureFull60() float64 {
+	if x != nil {
+		return x.IoPressureFull60
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetIoPressureFull300() float64 {
+	if x != nil {
+		return x.IoPressureFull300
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetOpenFds() uint64 {
+	if x != nil {
+		return x.OpenFds
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetMaxFds() uint64 {
+	if x != nil {
+		return x.MaxFds
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSocketsUsed() uint64 {
+	if x != nil {
+		return x.SocketsUsed
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetPerNet() map[string]*NetIO {
+	if x != nil {
+		return x.PerNet
+	}
+	return nil
+}
+
+func (x *NodeVmstat) GetPerDisk() map[string]*DiskIO {
+	if x != nil {
+		return x.PerDisk
+	}
+	return nil
+}
+
+func (x *NodeVmstat) GetCounterReset() bool {
+	if x != nil {
+		return x.CounterReset
+	}
+	return false
+}
+
+func (x *NodeVmstat) GetFailedCollectors() []string {
+	if x != nil {
+		return x.FailedCollectors
+	}
+	return nil
+}
+
+func (x *NodeVmstat) GetHugepagesTotal() uint64 {
+	if x != nil {
+		return x.HugepagesTotal
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetHugepagesFree() uint64 {
+	if x != nil {
+		return x.HugepagesFree
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetHugepagesRsvd() uint64 {
+	if x != nil {
+		return x.HugepagesRsvd
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetAnonHugepagesMb() uint64 {
+	if x != nil {
+		return x.AnonHugepagesMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNrDirty() uint64 {
+	if x != nil {
+		return x.NrDirty
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetNrWriteback() uint64 {
+	if x != nil {
+		return x.NrWriteback
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetConntrackCount() uint64 {
+	if x != nil {
+		return x.ConntrackCount
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetConntrackMax() uint64 {
+	if x != nil {
+		return x.ConntrackMax
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetTcpInSegs() uint64 {
+	if x != nil {
+		return x.TcpInSegs
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetTcpOutSegs() uint64 {
+	if x != nil {
+		return x.TcpOutSegs
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetTcpRetransSegs() uint64 {
+	if x != nil {
+		return x.TcpRetransSegs
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetProcsRunning() uint64 {
+	if x != nil {
+		return x.ProcsRunning
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetProcsBlocked() uint64 {
+	if x != nil {
+		return x.ProcsBlocked
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetEntropyAvail() uint64 {
+	if x != nil {
+		return x.EntropyAvail
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetEntropyPoolSize() uint64 {
+	if x != nil {
+		return x.EntropyPoolSize
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSlabMb() uint64 {
+	if x != nil {
+		return x.SlabMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSlabReclaimableMb() uint64 {
+	if x != nil {
+		return x.SlabReclaimableMb
+	}
+	return 0
+}
+
+func (x *NodeVmstat) GetSlabUnreclaimMb() uint64 {
+	if x != nil {
+		return x.SlabUnreclaimMb
+	}
+	return 0
+}
+
+var File_nodecollector_proto protoreflect.FileDescriptor
+
+const file_nodecollector_proto_rawDesc = "" +
+	"\n" +
+	"\x13nodecollector.proto\x12\x10nodecollector.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"D\n" +
+	"\x10SubscribeRequest\x12\x14\n" +
+	"\x05scope\x18\x01 \x01(\tR\x05scope\x12\x1a\n" +
+	"\bbackfill\x18\x02 \x01(\tR\bbackfill\"\x89\x01\n" +
+	"\x05Frame\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x124\n" +
+	"\x05stats\x18\x02 \x01(\v2\x1c.nodecollector.v1.NodeVmstatH\x00R\x05stats\x12/\n" +
+	"\x05event\x18\x03 \x01(\v2\x17.nodecollector.v1.EventH\x00R\x05eventB\t\n" +
+	"\apayload\"x\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12*\n" +
+	"\x02ts\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02ts\x12/\n" +
+	"\x06fields\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06fields\"\xb8\x02\n" +
+	"\x06DiskIO\x12\x15\n" +
+	"\x06read_b\x18\x01 \x01(\x04R\x05readB\x12\x17\n" +
+	"\awrite_b\x18\x02 \x01(\x04R\x06writeB\x12\x1d\n" +
+	"\n" +
+	"read_count\x18\x03 \x01(\x04R\treadCount\x12\x1f\n" +
+	"\vwrite_count\x18\x04 \x01(\x04R\n" +
+	"writeCount\x12\x1c\n" +
+	"\n" +
+	"io_time_ms\x18\x05 \x01(\x04R\bioTimeMs\x12$\n" +
+	"\x0eweighted_io_ms\x18\x06 \x01(\x04R\fweightedIoMs\x12\x1b\n" +
+	"\tin_flight\x18\a \x01(\x04R\binFlight\x12!\n" +
+	"\futil_percent\x18\b \x01(\x01R\vutilPercent\x12\x19\n" +
+	"\bawait_ms\x18\t \x01(\x01R\aawaitMs\x12\x1f\n" +
+	"\vqueue_depth\x18\n" +
+	" \x01(\x01R\n" +
+	"queueDepth\"\xdf\x01\n" +
+	"\x05NetIO\x12\x19\n" +
+	"\brx_bytes\x18\x01 \x01(\x04R\arxBytes\x12\x19\n" +
+	"\btx_bytes\x18\x02 \x01(\x04R\atxBytes\x12\x1d\n" +
+	"\n" +
+	"rx_packets\x18\x03 \x01(\x04R\trxPackets\x12\x1d\n" +
+	"\n" +
+	"tx_packets\x18\x04 \x01(\x04R\ttxPackets\x12\x17\n" +
+	"\arx_errs\x18\x05 \x01(\x04R\x06rxErrs\x12\x17\n" +
+	"\atx_errs\x18\x06 \x01(\x04R\x06txErrs\x12\x17\n" +
+	"\arx_drop\x18\a \x01(\x04R\x06rxDrop\x12\x17\n" +
+	"\atx_drop\x18\b \x01(\x04R\x06txDrop\"\x89\x1b\n" +
+	"\n" +
+	"NodeVmstat\x12*\n" +
+	"\x02ts\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x02ts\x12\x1f\n" +
+	"\vcpu_percent\x18\x02 \x01(\x01R\n" +
+	"cpuPercent\x12(\n" +
+	"\x10cpu_user_percent\x18\x03 \x01(\x01R\x0ecpuUserPercent\x12,\n" +
+	"\x12cpu_system_percent\x18\x04 \x01(\x01R\x10cpuSystemPercent\x12,\n" +
+	"\x12cpu_iowait_percent\x18\x05 \x01(\x01R\x10cpuIowaitPercent\x12*\n" +
+	"\x11cpu_steal_percent\x18\x06 \x01(\x01R\x0fcpuStealPercent\x12(\n" +
+	"\x10cpu_idle_percent\x18\a \x01(\x01R\x0ecpuIdlePercent\x12&\n" +
+	"\x0fper_cpu_percent\x18\b \x03(\x01R\rperCpuPercent\x12\x1e\n" +
+	"\vmem_used_mb\x18\t \x01(\x04R\tmemUsedMb\x12 \n" +
+	"\fmem_total_mb\x18\n" +
+	" \x01(\x04R\n" +
+	"memTotalMb\x12(\n" +
+	"\x10mem_available_mb\x18\v \x01(\x04R\x0ememAvailableMb\x12\"\n" +
+	"\rmem_cached_mb\x18\f \x01(\x04R\vmemCachedMb\x12$\n" +
+	"\x0emem_buffers_mb\x18\r \x01(\x04R\fmemBuffersMb\x12 \n" +
+	"\fswap_used_mb\x18\x0e \x01(\x04R\n" +
+	"swapUsedMb\x12\"\n" +
+	"\rswap_total_mb\x18\x0f \x01(\x04R\vswapTotalMb\x12\x16\n" +
+	"\x06pswpin\x18\x10 \x01(\x04R\x06pswpin\x12\x18\n" +
+	"\apswpout\x18\x11 \x01(\x04R\apswpout\x12\x18\n" +
+	"\apgfault\x18\x12 \x01(\x04R\apgfault\x12\x1e\n" +
+	"\n" +
+	"pgmajfault\x18\x13 \x01(\x04R\n" +
+	"pgmajfault\x12\x16\n" +
+	"\x06pgpgin\x18\x14 \x01(\x04R\x06pgpgin\x12\x18\n" +
+	"\apgpgout\x18\x15 \x01(\x04R\apgpgout\x12#\n" +
+	"\rswap_pressure\x18\x16 \x01(\x04R\fswapPressure\x12\x1d\n" +
+	"\n" +
+	"swap_level\x18\x17 \x01(\tR\tswapLevel\x12)\n" +
+	"\x10context_switches\x18\x18 \x01(\x04R\x0fcontextSwitches\x12\x1e\n" +
+	"\n" +
+	"interrupts\x18\x19 \x01(\x04R\n" +
+	"interrupts\x12\x1e\n" +
+	"\vdisk_read_b\x18\x1a \x01(\x04R\tdiskReadB\x12 \n" +
+	"\fdisk_write_b\x18\x1b \x01(\x04R\n" +
+	"diskWriteB\x12\"\n" +
+	"\rdisk_read_bps\x18\x1c \x01(\x04R\vdiskReadBps\x12$\n" +
+	"\x0edisk_write_bps\x18\x1d \x01(\x04R\fdiskWriteBps\x12\x14\n" +
+	"\x05load1\x18\x1e \x01(\x01R\x05load1\x12\x14\n" +
+	"\x05load5\x18\x1f \x01(\x01R\x05load5\x12\x16\n" +
+	"\x06load15\x18  \x01(\x01R\x06load15\x12 \n" +
+	"\fnet_rx_bytes\x18! \x01(\x04R\n" +
+	"netRxBytes\x12 \n" +
+	"\fnet_tx_bytes\x18\" \x01(\x04R\n" +
+	"netTxBytes\x12$\n" +
+	"\x0enet_rx_packets\x18# \x01(\x04R\fnetRxPackets\x12$\n" +
+	"\x0enet_tx_packets\x18$ \x01(\x04R\fnetTxPackets\x12\x1e\n" +
+	"\vnet_rx_errs\x18% \x01(\x04R\tnetRxErrs\x12\x1e\n" +
+	"\vnet_tx_errs\x18& \x01(\x04R\tnetTxErrs\x12\x1e\n" +
+	"\vnet_rx_drop\x18' \x01(\x04R\tnetRxDrop\x12\x1e\n" +
+	"\vnet_tx_drop\x18( \x01(\x04R\tnetTxDrop\x12\x1c\n" +
+	"\n" +
+	"net_rx_bps\x18) \x01(\x04R\bnetRxBps\x12\x1c\n" +
+	"\n" +
+	"net_tx_bps\x18* \x01(\x04R\bnetTxBps\x12.\n" +
+	"\x13cpu_pressure_some10\x18+ \x01(\x01R\x11cpuPressureSome10\x12.\n" +
+	"\x13cpu_pressure_some60\x18, \x01(\x01R\x11cpuPressureSome60\x120\n" +
+	"\x14cpu_pressure_some300\x18- \x01(\x01R\x12cpuPressureSome300\x12.\n" +
+	"\x13cpu_pressure_full10\x18. \x01(\x01R\x11cpuPressureFull10\x12.\n" +
+	"\x13cpu_pressure_full60\x18/ \x01(\x01R\x11cpuPressureFull60\x120\n" +
+	"\x14cpu_pressure_full300\x180 \x01(\x01R\x12cpuPressureFull300\x12.\n" +
+	"\x13mem_pressure_some10\x181 \x01(\x01R\x11memPressureSome10\x12.\n" +
+	"\x13mem_pressure_some60\x182 \x01(\x01R\x11memPressureSome60\x120\n" +
+	"\x14mem_pressure_some300\x183 \x01(\x01R\x12memPressureSome300\x12.\n" +
+	"\x13mem_pressure_full10\x184 \x01(\x01R\x11memPressureFull10\x12.\n" +
+	"\x13mem_pressure_full60\x185 \x01(\x01R\x11memPressureFull60\x120\n" +
+	"\x14mem_pressure_full300\x186 \x01(\x01R\x12memPressureFull300\x12,\n" +
+	"\x12io_pressure_some10\x187 \x01(\x01R\x10ioPressureSome10\x12,\n" +
+	"\x12io_pressure_some60\x188 \x01(\x01R\x10ioPressureSome60\x12.\n" +
+	"\x13io_pressure_some300\x189 \x01(\x01R\x11ioPressureSome300\x12,\n" +
+	"\x12io_pressure_full10\x18: \x01(\x01R\x10ioPressureFull10\x12,\n" +
+	"\x12io_pressure_full60\x18; \x01(\x01R\x10ioPressureFull60\x12.\n" +
+	"\x13io_pressure_full300\x18< \x01(\x01R\x11ioPressureFull300\x12\x19\n" +
+	"\bopen_fds\x18= \x01(\x04R\aopenFds\x12\x17\n" +
+	"\amax_fds\x18> \x01(\x04R\x06maxFds\x12!\n" +
+	"\fsockets_used\x18? \x01(\x04R\vsocketsUsed\x12A\n" +
+	"\aper_net\x18@ \x03(\v2(.nodecollector.v1.NodeVmstat.PerNetEntryR\x06perNet\x12D\n" +
+	"\bper_disk\x18A \x03(\v2).nodecollector.v1.NodeVmstat.PerDiskEntryR\aperDisk\x12#\n" +
+	"\rcounter_reset\x18B \x01(\bR\fcounterReset\x12+\n" +
+	"\x11failed_collectors\x18C \x03(\tR\x10failedCollectors\x12'\n" +
+	"\x0fhugepages_total\x18D \x01(\x04R\x0ehugepagesTotal\x12%\n" +
+	"\x0ehugepages_free\x18E \x01(\x04R\rhugepagesFree\x12%\n" +
+	"\x0ehugepages_rsvd\x18F \x01(\x04R\rhugepagesRsvd\x12*\n" +
+	"\x11anon_hugepages_mb\x18G \x01(\x04R\x0fanonHugepagesMb\x12\x19\n" +
+	"\bnr_dirty\x18H \x01(\x04R\anrDirty\x12!\n" +
+	"\fnr_writeback\x18I \x01(\x04R\vnrWriteback\x12'\n" +
+	"\x0fconntrack_count\x18J \x01(\x04R\x0econntrackCount\x12#\n" +
+	"\rconntrack_max\x18K \x01(\x04R\fconntrackMax\x12\x1e\n" +
+	"\vtcp_in_segs\x18L \x01(\x04R\ttcpInSegs\x12 \n" +
+	"\ftcp_out_segs\x18M \x01(\x04R\n" +
+	"tcpOutSegs\x12(\n" +
+	"\x10tcp_retrans_segs\x18N \x01(\x04R\x0etcpRetransSegs\x12#\n" +
+	"\rprocs_running\x18O \x01(\x04R\fprocsRunning\x12#\n" +
+	"\rprocs_blocked\x18P \x01(\x04R\fprocsBlocked\x12#\n" +
+	"\rentropy_avail\x18Q \x01(\x04R\fentropyAvail\x12*\n" +
+	"\x11entropy_pool_size\x18R \x01(\x04R\x0fentropyPoolSize\x12\x17\n" +
+	"\aslab_mb\x18S \x01(\x04R\x06slabMb\x12.\n" +
+	"\x13slab_reclaimable_mb\x18T \x01(\x04R\x11slabReclaimableMb\x12*\n" +
+	"\x11slab_unreclaim_mb\x18U \x01(\x04R\x0fslabUnreclaimMb\x1aR\n" +
+	"\vPerNetEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12-\n" +
+	"\x05value\x18\x02 \x01(\v2\x17.nodecollector.v1.NetIOR\x05value:\x028\x01\x1aT\n" +
+	"\fPerDiskEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12.\n" +
+	"\x05value\x18\x02 \x01(\v2\x18.nodecollector.v1.DiskIOR\x05value:\x028\x012[\n" +
+	"\rNodeCollector\x12J\n" +
+	"\tSubscribe\x12\".nodecollector.v1.SubscribeRequest\x1a\x17.nodecollector.v1.Frame0\x01B<Z:github.com/ajaysundark/nodecollector/proto;nodecollectorpbb\x06proto3"
+
+var (
+	file_nodecollector_proto_rawDescOnce sync.Once
+	file_nodecollector_proto_rawDescData []byte
+)
+
+func file_nodecollector_proto_rawDescGZIP() []byte {
+	file_nodecollector_proto_rawDescOnce.Do(func() {
+		file_nodecollector_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_nodecollector_proto_rawDesc), len(file_nodecollector_proto_rawDesc)))
+	})
+	return file_nodecollector_proto_rawDescData
+}
+
+var file_nodecollector_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_nodecollector_proto_goTypes = []any{
+	(*SubscribeRequest)(nil),      // 0: nodecollector.v1.SubscribeRequest
+	(*Frame)(nil),                 // 1: nodecollector.v1.Frame
+	(*Event)(nil),                 // 2: nodecollector.v1.Event
+	(*DiskIO)(nil),                // 3: nodecollector.v1.DiskIO
+	(*NetIO)(nil),                 // 4: nodecollector.v1.NetIO
+	(*NodeVmstat)(nil),            // 5: nodecollector.v1.NodeVmstat
+	nil,                           // 6: nodecollector.v1.NodeVmstat.PerNetEntry
+	nil,                           // 7: nodecollector.v1.NodeVmstat.PerDiskEntry
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 9: google.protobuf.Struct
+}
+var file_nodecollector_proto_depIdxs = []int32{
+	5,  // 0: nodecollector.v1.Frame.stats:type_name -> nodecollector.v1.NodeVmstat
+	2,  // 1: nodecollector.v1.Frame.event:type_name -> nodecollector.v1.Event
+	8,  // 2: nodecollector.v1.Event.ts:type_name -> google.protobuf.Timestamp
+	9,  // 3: nodecollector.v1.Event.fields:type_name -> google.protobuf.Struct
+	8,  // 4: nodecollector.v1.NodeVmstat.ts:type_name -> google.protobuf.Timestamp
+	6,  // 5: nodecollector.v1.NodeVmstat.per_net:type_name -> nodecollector.v1.NodeVmstat.PerNetEntry
+	7,  // 6: nodecollector.v1.NodeVmstat.per_disk:type_name -> nodecollector.v1.NodeVmstat.PerDiskEntry
+	4,  // 7: nodecollector.v1.NodeVmstat.PerNetEntry.value:type_name -> nodecollector.v1.NetIO
+	3,  // 8: nodecollector.v1.NodeVmstat.PerDiskEntry.value:type_name -> nodecollector.v1.DiskIO
+	0,  // 9: nodecollector.v1.NodeCollector.Subscribe:input_type -> nodecollector.v1.SubscribeRequest
+	1,  // 10: nodecollector.v1.NodeCollector.Subscribe:output_type -> nodecollector.v1.Frame
+	10, // [10:11] is the sub-list for method output_type
+	9,  // [9:10] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_nodecollector_proto_init() }
+func file_nodecollector_proto_init() {
+	if File_nodecollector_proto != nil {
+		return
+	}
+	file_nodecollector_proto_msgTypes[1].OneofWrappers = []any{
+		(*Frame_Stats)(nil),
+		(*Frame_Event)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_nodecollector_proto_rawDesc), len(file_nodecollector_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_nodecollector_proto_goTypes,
+		DependencyIndexes: file_nodecollector_proto_depIdxs,
+		MessageInfos:      file_nodecollector_proto_msgTypes,
+	}.Build()
+	File_nodecollector_proto = out.File
+	file_nodecollector_proto_goTypes = nil
+	file_nodecollector_proto_depIdxs = nil
+}
@@ -0,0 +1,131 @@
+// The nodecollector streaming API, an optional typed alternative to the
+// /stream SSE endpoint. Field names match the JSON keys served over HTTP.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: nodecollector.proto
+
+package nodecollectorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NodeCollector_Subscribe_FullMethodName = "/nodecollector.v1.NodeCollector/Subscribe"
+)
+
+// NodeCollectorClient is the client API for NodeCollector service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NodeCollectorClient interface {
+	// Subscribe streams the frames of a scope as /stream does: events as
+	// they are ingested, stats once per sample interval.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Frame], error)
+}
+
+type nodeCollectorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeCollectorClient(cc grpc.ClientConnInterface) NodeCollectorClient {
+	return &nodeCollectorClient{cc}
+}
+
+func (c *nodeCollectorClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Frame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NodeCollector_ServiceDesc.Streams[0], NodeCollector_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Frame]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NodeCollector_SubscribeClient = grpc.ServerStreamingClient[Frame]
+
+// NodeCollectorServer is the server API for NodeCollector service.
+// All implementations must embed UnimplementedNodeCollectorServer
+// for forward compatibility.
+type NodeCollectorServer interface {
+	// Subscribe streams the frames of a scope as /stream does: events as
+	// they are ingested, stats once per sample interval.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Frame]) error
+	mustEmbedUnimplementedNodeCollectorServer()
+}
+
+// UnimplementedNodeCollectorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNodeCollectorServer struct{}
+
+func (UnimplementedNodeCollectorServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Frame]) error {
+	return status.Error(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedNodeCollectorServer) mustEmbedUnimplementedNodeCollectorServer() {}
+func (UnimplementedNodeCollectorServer) testEmbeddedByValue()                       {}
+
+// UnsafeNodeCollectorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NodeCollectorServer will
+// result in compilation errors.
+type UnsafeNodeCollectorServer interface {
+	mustEmbedUnimplementedNodeCollectorServer()
+}
+
+func RegisterNodeCollectorServer(s grpc.ServiceRegistrar, srv NodeCollectorServer) {
+	// If the following call panics, it indicates UnimplementedNodeCollectorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NodeCollector_ServiceDesc, srv)
+}
+
+func _NodeCollector_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeCollectorServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Frame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NodeCollector_SubscribeServer = grpc.ServerStreamingServer[Frame]
+
+// NodeCollector_ServiceDesc is the grpc.ServiceDesc for NodeCollector service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NodeCollector_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nodecollector.v1.NodeCollector",
+	HandlerType: (*NodeCollectorServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _NodeCollector_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "nodecollector.proto",
+}